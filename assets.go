@@ -0,0 +1,156 @@
+package swagger
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/oarkflow/frame"
+	"golang.org/x/net/webdav"
+)
+
+// assetAllowed reports whether path may be served under Config.AllowedAssets.
+func assetAllowed(config *Config, path string) bool {
+	if len(config.AllowedAssets) == 0 {
+		return true
+	}
+	path = strings.TrimPrefix(path, "/")
+	for _, allowed := range config.AllowedAssets {
+		if strings.TrimPrefix(allowed, "/") == path {
+			return true
+		}
+	}
+	return false
+}
+
+// assetCacheControl returns the Cache-Control header for an asset. Fingerprinted
+// assets never change under the same name, so they may be cached forever.
+func assetCacheControl(config *Config, path string) string {
+	if fingerprintMatcher.MatchString(strings.TrimPrefix(path, "/")) {
+		return "public, max-age=31536000, immutable"
+	}
+	return "public, max-age=" + strconv.Itoa(int(config.AssetMaxAge.Seconds()))
+}
+
+// assetCache keeps the contents of assets read from Config.Handler in memory.
+type assetCache struct {
+	mu       sync.RWMutex
+	files    map[string][]byte
+	missing  map[string]struct{}
+	flight   flightGroup
+	counters cacheCounters
+}
+
+func newAssetCache() *assetCache {
+	return &assetCache{files: make(map[string][]byte), missing: make(map[string]struct{})}
+}
+
+// read returns the asset at path, reading it from the file system on a cache miss.
+func (a *assetCache) read(c context.Context, config *Config, path string) ([]byte, error) {
+	if config.DevMode {
+		return readAsset(c, config, path)
+	}
+	a.mu.RLock()
+	data, ok := a.files[path]
+	a.mu.RUnlock()
+	a.counters.record(ok)
+	if ok {
+		return data, nil
+	}
+	return a.flight.do(path, func() ([]byte, error) {
+		data, err := readAsset(c, config, path)
+		if err != nil {
+			return nil, err
+		}
+		a.mu.Lock()
+		a.files[path] = data
+		a.mu.Unlock()
+		return data, nil
+	})
+}
+
+// precompressed returns the gzip encoded variant shipped next to the asset at path as `path.gz`,
+// if there is one. Assets without a variant are remembered so the lookup is only made once.
+func (a *assetCache) precompressed(c context.Context, config *Config, path string) ([]byte, bool) {
+	gzPath := path + ".gz"
+	if config.DevMode {
+		data, err := readAsset(c, config, gzPath)
+		return data, err == nil
+	}
+	a.mu.RLock()
+	_, missing := a.missing[gzPath]
+	data, ok := a.files[gzPath]
+	a.mu.RUnlock()
+	// The lookup accompanies the read of the asset, which alone counts towards stats.json.
+	if missing {
+		return nil, false
+	}
+	if ok {
+		return data, true
+	}
+	data, err := a.flight.do(gzPath, func() ([]byte, error) {
+		data, err := readAsset(c, config, gzPath)
+		a.mu.Lock()
+		if err != nil {
+			a.missing[gzPath] = struct{}{}
+		} else {
+			a.files[gzPath] = data
+		}
+		a.mu.Unlock()
+		return data, err
+	})
+	return data, err == nil
+}
+
+// reset empties the cache.
+func (a *assetCache) reset() {
+	a.mu.Lock()
+	a.files = make(map[string][]byte)
+	a.missing = make(map[string]struct{})
+	a.mu.Unlock()
+}
+
+// streamAsset hands the asset at path, or its precompressed variant, to the response as a
+// stream, so it is copied to the connection in small chunks instead of being held in memory.
+func streamAsset(c context.Context, ctx *frame.Context, config *Config, path string) error {
+	if f, err := config.Handler.FileSystem.OpenFile(c, path+".gz", os.O_RDONLY, 0); err == nil {
+		ctx.Header("Vary", "Accept-Encoding")
+		if acceptsGzip(ctx) {
+			ctx.Header("Content-Encoding", "gzip")
+			return streamFile(ctx, f)
+		}
+		f.Close()
+	}
+	f, err := config.Handler.FileSystem.OpenFile(c, path, os.O_RDONLY, 0)
+	if err != nil {
+		return err
+	}
+	return streamFile(ctx, f)
+}
+
+// streamFile sets f as the response body; the response closes it once sent.
+func streamFile(ctx *frame.Context, f webdav.File) error {
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	ctx.SetBodyStream(f, int(info.Size()))
+	return nil
+}
+
+func readAsset(c context.Context, config *Config, path string) ([]byte, error) {
+	f, err := config.Handler.FileSystem.OpenFile(c, path, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	buf := new(bytes.Buffer)
+	if _, err = buf.ReadFrom(f); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,133 @@
+package swagger
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/oarkflow/frame"
+)
+
+// minCompressSize is the smallest body worth compressing.
+const minCompressSize = 1024
+
+// validCompressionLevel reports whether level is accepted by compress/gzip.
+func validCompressionLevel(level int) bool {
+	return level >= gzip.HuffmanOnly && level <= gzip.BestCompression
+}
+
+// acceptsGzip reports whether the client accepts a gzip encoded response. An explicit gzip
+// entry takes precedence over `*`, and a q-value of zero refuses the encoding.
+func acceptsGzip(ctx *frame.Context) bool {
+	wildcard := false
+	for _, enc := range strings.Split(string(ctx.GetHeader("Accept-Encoding")), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(enc), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != "gzip" && name != "*" {
+			continue
+		}
+		accepted := qValue(params) > 0
+		if name == "gzip" {
+			return accepted
+		}
+		wildcard = accepted
+	}
+	return wildcard
+}
+
+// qValue returns the q parameter among the parameters of an Accept-Encoding entry, 1 if there
+// is none and 0 if it is malformed.
+func qValue(params string) float64 {
+	for _, param := range strings.Split(params, ";") {
+		key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		if !strings.EqualFold(strings.TrimSpace(key), "q") {
+			continue
+		}
+		q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return 0
+		}
+		return q
+	}
+	return 1
+}
+
+// compressCache keeps gzip encoded bodies so each one is only compressed once.
+type compressCache struct {
+	mu       sync.RWMutex
+	files    map[string][]byte
+	sizes    map[string]int
+	flight   flightGroup
+	counters cacheCounters
+}
+
+func newCompressCache() *compressCache {
+	return &compressCache{files: make(map[string][]byte), sizes: make(map[string]int)}
+}
+
+// compress returns data gzip encoded. Results are cached under key unless key is empty.
+func (cc *compressCache) compress(config *Config, key string, data []byte) ([]byte, error) {
+	if key == "" || config.DevMode || config.LowMemoryMode {
+		return gzipEncode(data, config.CompressionLevel)
+	}
+	cc.mu.RLock()
+	gz, ok := cc.files[key]
+	cc.mu.RUnlock()
+	cc.counters.record(ok)
+	if ok {
+		return gz, nil
+	}
+	return cc.flight.do(key, func() ([]byte, error) {
+		gz, err := gzipEncode(data, config.CompressionLevel)
+		if err != nil {
+			return nil, err
+		}
+		cc.mu.Lock()
+		if name, _, ok := strings.Cut(key, "@"); ok {
+			// A contentKey replaces the older versions of the same body.
+			for cached := range cc.files {
+				if strings.HasPrefix(cached, name+"@") {
+					delete(cc.files, cached)
+					delete(cc.sizes, cached)
+				}
+			}
+		}
+		cc.files[key] = gz
+		cc.sizes[key] = len(data)
+		cc.mu.Unlock()
+		return gz, nil
+	})
+}
+
+// gzipEncode returns data gzip encoded at level.
+func gzipEncode(data []byte, level int) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	zw, err := gzip.NewWriterLevel(buf, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// reset empties the cache.
+func (cc *compressCache) reset() {
+	cc.mu.Lock()
+	cc.files = make(map[string][]byte)
+	cc.sizes = make(map[string]int)
+	cc.mu.Unlock()
+}
+
+// contentKey keys dynamic bodies, such as the spec, by their content.
+func contentKey(name string, data []byte) string {
+	sum := sha256.Sum256(data)
+	return name + "@" + string(sum[:])
+}
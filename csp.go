@@ -0,0 +1,61 @@
+package swagger
+
+import (
+	"net/url"
+	"strings"
+)
+
+// contentSecurityPolicy builds the policy sent with the docs pages, allowing for the CDNs of the renderers
+// besides Swagger UI. The pages rely on inline scripts and styles, and on the fonts of Google Fonts;
+// the spec may be fetched from the origin of URL and Try it out may call any of APIOrigins.
+func contentSecurityPolicy(config *Config, renderers []string) string {
+	redoc, rapidoc := false, false
+	for _, renderer := range renderers {
+		redoc = redoc || renderer == RendererReDoc
+		rapidoc = rapidoc || renderer == RendererRapiDoc
+	}
+	scripts := []string{"'self'", "'unsafe-inline'"}
+	connect := []string{"'self'"}
+	images := []string{"'self'", "data:"}
+	if redoc {
+		scripts = append(scripts, "https://cdn.redoc.ly")
+	}
+	if rapidoc {
+		scripts = append(scripts, "https://unpkg.com")
+	}
+	if config.googleAnalyticsID() != "" {
+		scripts = append(scripts, "https://www.googletagmanager.com")
+		connect = append(connect, "https://*.google-analytics.com")
+	}
+	if validator := config.validatorURL(); validator != "" {
+		if origin := originOf(validator); origin != "" {
+			images = append(images, origin)
+		}
+	}
+	if origin := originOf(config.URL); origin != "" {
+		connect = append(connect, origin)
+	}
+	connect = append(connect, config.APIOrigins...)
+
+	directives := []string{
+		"default-src 'self'",
+		"script-src " + strings.Join(scripts, " "),
+		"style-src 'self' 'unsafe-inline' https://fonts.googleapis.com",
+		"font-src 'self' https://fonts.gstatic.com",
+		"img-src " + strings.Join(images, " "),
+		"connect-src " + strings.Join(connect, " "),
+	}
+	if redoc {
+		directives = append(directives, "worker-src 'self' blob:")
+	}
+	return strings.Join(directives, "; ")
+}
+
+// originOf returns the scheme and host of rawURL, or "" if it has none.
+func originOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
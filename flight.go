@@ -0,0 +1,43 @@
+package swagger
+
+import "sync"
+
+// flightGroup collapses concurrent calls for the same key into one, so a burst of requests
+// for an uncached asset reads or compresses it once and shares the result.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flight
+}
+
+// flight is a call in progress or just completed.
+type flight struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+// do runs fn for key unless a call for key is already running, in which case
+// it waits for that call and returns its result.
+func (g *flightGroup) do(key string, fn func() ([]byte, error)) ([]byte, error) {
+	g.mu.Lock()
+	if call, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		<-call.done
+		return call.data, call.err
+	}
+	if g.calls == nil {
+		g.calls = make(map[string]*flight)
+	}
+	call := &flight{done: make(chan struct{})}
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(call.done)
+	}()
+	call.data, call.err = fn()
+	return call.data, call.err
+}
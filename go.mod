@@ -4,6 +4,7 @@ go 1.20
 
 require (
 	github.com/oarkflow/frame v0.0.36
+	github.com/oarkflow/log v1.0.73
 	github.com/swaggo/files v1.0.0
 	github.com/swaggo/swag v1.8.10
 	golang.org/x/net v0.8.0
@@ -29,7 +30,6 @@ require (
 	github.com/klauspost/cpuid/v2 v2.0.9 // indirect
 	github.com/mailru/easyjson v0.7.6 // indirect
 	github.com/nyaruka/phonenumbers v1.0.55 // indirect
-	github.com/oarkflow/log v1.0.73 // indirect
 	github.com/tidwall/gjson v1.9.3 // indirect
 	github.com/tidwall/match v1.1.1 // indirect
 	github.com/tidwall/pretty v1.2.0 // indirect
@@ -0,0 +1,575 @@
+package swagger
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"math"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oarkflow/frame"
+	"github.com/oarkflow/frame/middlewares/server/basic_auth"
+	"github.com/oarkflow/frame/pkg/protocol/consts"
+	"github.com/oarkflow/log"
+)
+
+// matcher matches the files served under the mount, relative to it. Matching the path below the mount
+// rather than the whole URI keeps the mount prefix out of it, which is empty when mounted at the root.
+var matcher = regexp.MustCompile(`^(index\.html|doc\.json|operations\.json|index\.json|stats\.json|favicon-16x16\.png|favicon-32x32\.png|oauth2-redirect\.html|swagger-ui\.css(?:\.map)?|swagger-ui\.js(?:\.map)?|swagger-ui-bundle\.js(?:\.map)?|swagger-ui-standalone-preset\.js(?:\.map)?|redoc|rapidoc|reload)$`)
+
+// fingerprintMatcher matches assets whose name carries a content hash, such as `swagger-ui-bundle.3f2a9c1b.js`.
+var fingerprintMatcher = regexp.MustCompile(`^[\w-]+[.-][0-9a-f]{8,64}\.(?:js|css|png)(?:\.map)?$`)
+
+// handler holds the state shared by every request to a mount.
+type handler struct {
+	config    *Config
+	csp       string
+	index     *template.Template
+	indexErr  error
+	errorTpl  *template.Template
+	renderers map[string]*template.Template
+	spec      *specFile
+	assets    *assetCache
+	gzipped   *compressCache
+	docs      docCache
+	auth      frame.HandlerFunc
+	limiter   *rateLimiter
+	once      sync.Once
+}
+
+// newHandler builds the handler serving the page rendered from indexTpl along with doc.json and the assets.
+func newHandler(name, indexTpl string, cfg ...*Config) *handler {
+	config := prepareConfig(cfg...)
+	if err := config.validate(); err != nil {
+		log.Error().Str("log_service", "Swagger").Msgf("[Swagger] invalid config, ignoring what can't be used: %v", err)
+	}
+
+	config.fallBackToDefaults()
+
+	h := &handler{
+		config:  config,
+		assets:  newAssetCache(),
+		gzipped: newCompressCache(),
+	}
+	if config.ContentSecurityPolicy {
+		renderers := config.Renderers
+		if name == "redoc_index.html" {
+			renderers = append([]string{RendererReDoc}, renderers...)
+		}
+		h.csp = contentSecurityPolicy(config, renderers)
+	}
+	if config.SpecFile != "" {
+		h.spec = newSpecFile(config.SpecFile, config.WatchSpecFile)
+	}
+	if len(config.BasicAuth) > 0 {
+		h.auth = basic_auth.BasicAuthForRealm(config.BasicAuth, config.Title, "user")
+	}
+	if config.RateLimit != nil && config.RateLimit.Requests > 0 && config.RateLimit.Interval > 0 {
+		h.limiter = newRateLimiter(config.RateLimit)
+	}
+
+	h.renderers = newRenderers(config.Renderers)
+	if config.ErrorTemplate != "" {
+		if tpl, err := template.New("error.html").Parse(config.ErrorTemplate); err == nil {
+			h.errorTpl = tpl
+		}
+	}
+
+	if config.IndexTemplate != "" {
+		indexTpl = config.IndexTemplate
+	}
+	// create a template with name
+	h.index = template.New(name)
+	// The stock page uses no functions, so TemplateFuncs without IndexTemplate are ignored.
+	if len(config.TemplateFuncs) > 0 && config.IndexTemplate != "" {
+		h.index.Funcs(config.TemplateFuncs)
+	}
+	_, h.indexErr = h.index.Parse(indexTpl)
+	return h
+}
+
+// close stops the background work of the handler, the watching of a SpecFile.
+func (h *handler) close() {
+	if h.spec != nil {
+		h.spec.close()
+	}
+}
+
+// guard applies rate limiting, Disabled, the method check and auth. It reports whether the request may proceed.
+func (h *handler) guard(c context.Context, ctx *frame.Context) bool {
+	config := h.config
+	if h.limiter != nil {
+		if ok, wait := h.limiter.allow(ctx.ClientIP(), time.Now()); !ok {
+			ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			h.abortWithMessage(ctx, http.StatusTooManyRequests, http.StatusText(http.StatusTooManyRequests))
+			return false
+		}
+	}
+
+	if config.Disabled {
+		h.abortWithMessage(ctx, config.DisabledStatus, config.DisabledMessage)
+		return false
+	}
+
+	if string(ctx.Request.Method()) != consts.MethodGet {
+		h.abortWithError(ctx, http.StatusMethodNotAllowed, nil)
+		return false
+	}
+
+	if h.auth != nil {
+		if h.auth(c, ctx); ctx.IsAborted() {
+			return false
+		}
+	}
+	return true
+}
+
+func (h *handler) serve(c context.Context, ctx *frame.Context) {
+	if !h.guard(c, ctx) {
+		return
+	}
+	config := h.config
+
+	path := strings.TrimPrefix(ctx.Param("any"), "/")
+	if path == "" {
+		if len(config.Renderers) > 0 {
+			h.writeLanding(ctx)
+			return
+		}
+		h.redirectRoot(ctx)
+		return
+	}
+	if !matcher.MatchString(path) && !fingerprintMatcher.MatchString(path) {
+		h.abortWithMessage(ctx, http.StatusNotFound, http.StatusText(http.StatusNotFound))
+		return
+	}
+	// The mount prefix is what precedes path, `/` when mounted at the root.
+	prefix := strings.TrimSuffix(string(ctx.Path()), path)
+
+	h.once.Do(func() {
+		handlerPrefix := prefix
+		if config.AssetPrefix != "" {
+			handlerPrefix = config.AssetPrefix
+		} else if config.PublicBasePath != "" {
+			handlerPrefix = config.PublicBasePath
+		}
+		config.Handler.Prefix = handlerPrefix
+	})
+
+	setContentType(ctx, config, path)
+
+	if config.DevMode {
+		ctx.Header("Cache-Control", "no-store")
+	}
+
+	switch path {
+	case "index.html":
+		h.writePage(ctx, h.index, h.indexErr, prefix)
+	case RendererReDoc, RendererRapiDoc:
+		tpl, ok := h.renderers[path]
+		if !ok {
+			h.abortWithMessage(ctx, http.StatusNotFound, http.StatusText(http.StatusNotFound))
+			return
+		}
+		ctx.Header("Content-Type", "text/html; charset=utf-8")
+		h.writePage(ctx, tpl, nil, prefix)
+	case "doc.json":
+		h.writeDoc(ctx)
+	case "operations.json":
+		doc, err := h.loadDoc(ctx)
+		if err != nil {
+			h.abortWithError(ctx, http.StatusInternalServerError, err)
+			return
+		}
+		ops, err := listOperations(doc)
+		if err != nil {
+			h.abortWithError(ctx, http.StatusInternalServerError, err)
+			return
+		}
+		data, err := json.Marshal(ops)
+		if err != nil {
+			h.abortWithError(ctx, http.StatusInternalServerError, err)
+			return
+		}
+		ctx.Header("Content-Type", config.JSONContentType)
+		h.writeBody(ctx, "", data)
+	case "index.json":
+		data, err := json.Marshal(newDocsIndex(config))
+		if err != nil {
+			h.abortWithError(ctx, http.StatusInternalServerError, err)
+			return
+		}
+		h.writeBody(ctx, "", data)
+	case "stats.json":
+		if !config.EnableStats {
+			h.abortWithMessage(ctx, http.StatusNotFound, http.StatusText(http.StatusNotFound))
+			return
+		}
+		data, err := json.Marshal(h.stats())
+		if err != nil {
+			h.abortWithError(ctx, http.StatusInternalServerError, err)
+			return
+		}
+		ctx.Header("Cache-Control", "no-store")
+		h.writeBody(ctx, "", data)
+	case "reload":
+		if !config.DevMode {
+			h.abortWithMessage(ctx, http.StatusNotFound, http.StatusText(http.StatusNotFound))
+			return
+		}
+		if h.spec != nil {
+			h.spec.reset()
+		}
+		h.docs.reset()
+		h.assets.reset()
+		h.gzipped.reset()
+		ctx.Status(http.StatusNoContent)
+
+	default:
+		if !assetAllowed(config, path) {
+			h.abortWithMessage(ctx, http.StatusNotFound, http.StatusText(http.StatusNotFound))
+			return
+		}
+		if !config.DevMode {
+			ctx.Header("Cache-Control", assetCacheControl(config, path))
+		}
+		if config.LowMemoryMode {
+			if err := streamAsset(c, ctx, config, path); err != nil {
+				h.abortWithError(ctx, http.StatusInternalServerError, err)
+			}
+			return
+		}
+		if gz, ok := h.assets.precompressed(c, config, path); ok {
+			ctx.Header("Vary", "Accept-Encoding")
+			if acceptsGzip(ctx) {
+				ctx.Header("Content-Encoding", "gzip")
+				if _, err := ctx.Write(gz); err != nil {
+					h.abortWithError(ctx, http.StatusInternalServerError, err)
+				}
+				return
+			}
+		}
+		data, err := h.assets.read(c, config, path)
+		if err != nil {
+			h.abortWithError(ctx, http.StatusInternalServerError, err)
+			return
+		}
+		h.writeBody(ctx, path, data)
+	}
+}
+
+// redirectRoot sends requests for the bare mount path to RootRedirect, resolved against the mount.
+func (h *handler) redirectRoot(ctx *frame.Context) {
+	target := h.config.RootRedirect
+	if !strings.HasPrefix(target, "/") && !strings.Contains(target, "://") {
+		base := h.config.PublicBasePath
+		if base == "" {
+			base = string(ctx.Path())
+		}
+		if !strings.HasSuffix(base, "/") {
+			base += "/"
+		}
+		target = base + target
+	}
+	ctx.Redirect(http.StatusFound, []byte(target))
+}
+
+// serveSpec serves the spec document regardless of the request path.
+func (h *handler) serveSpec(c context.Context, ctx *frame.Context) {
+	if !h.guard(c, ctx) {
+		return
+	}
+	setContentType(ctx, h.config, "doc.json")
+	if h.config.DevMode {
+		ctx.Header("Cache-Control", "no-store")
+	}
+	h.writeDoc(ctx)
+}
+
+// writePage renders a docs page, such as index.html, from tpl unless parsing it failed with tplErr.
+// The page is rendered in full before anything is written, so a failing custom template results
+// in a clean `500`.
+func (h *handler) writePage(ctx *frame.Context, tpl *template.Template, tplErr error, mount string) {
+	err := tplErr
+	var page []byte
+	if err == nil {
+		page, err = renderTemplate(tpl, h.pageConfig(ctx, mount))
+	}
+	if err != nil {
+		log.Error().Str("log_service", "Swagger").Msgf("[Swagger] rendering %s: %v", tpl.Name(), err)
+		message := "failed to render the docs page, see the server log for details"
+		if h.config.DevMode {
+			message = err.Error()
+		}
+		if !h.errorPage(ctx, http.StatusInternalServerError, message) {
+			ctx.AbortWithMsg(message, http.StatusInternalServerError)
+		}
+		return
+	}
+	if h.csp != "" {
+		ctx.Header("Content-Security-Policy", h.csp)
+	}
+	if h.config.AuthorizeForTryItOut != nil && !h.config.DevMode {
+		// The page differs per caller, so shared caches must not keep it.
+		ctx.Header("Cache-Control", "private")
+	}
+	h.writeBody(ctx, "", page)
+}
+
+// pageConfig returns the values a docs page is rendered with for the request in ctx,
+// which arrived on the mount path mount.
+func (h *handler) pageConfig(ctx *frame.Context, mount string) swaggerConfig {
+	data := h.config.toSwaggerConfig()
+	// Link from the mount rather than the page, so links resolve whether
+	// or not the page was reached with a trailing slash.
+	mount = h.mountPath(mount)
+	if h.config.AssetPrefix == "" {
+		data.AssetPrefix = mount
+	}
+	if isRelativeURL(data.URL) {
+		data.URL = mount + strings.TrimPrefix(data.URL, "./")
+	}
+	if h.config.AuthorizeForTryItOut != nil && !h.config.AuthorizeForTryItOut(ctx) {
+		data.TryItOutDisabled = true
+	}
+	if h.config.AllowQueryOverrides {
+		if depth, err := strconv.Atoi(ctx.Query("expandDepth")); err == nil && depth >= -1 {
+			data.DefaultModelsExpandDepth = depth
+		}
+	}
+	return data
+}
+
+// mountPath returns the path clients reach the mount at, with a trailing slash:
+// PublicBasePath if set, or else mount, the one the request arrived on.
+func (h *handler) mountPath(mount string) string {
+	if h.config.PublicBasePath != "" {
+		mount = h.config.PublicBasePath
+	}
+	if !strings.HasSuffix(mount, "/") {
+		mount += "/"
+	}
+	return mount
+}
+
+// isRelativeURL reports whether url is a path relative to the page, such as `doc.json`.
+func isRelativeURL(url string) bool {
+	return url != "" && !strings.HasPrefix(url, "/") && !strings.Contains(url, "://")
+}
+
+// renderTemplate executes tpl, turning a panic into an error.
+func renderTemplate(tpl *template.Template, data interface{}) (page []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("template panicked: %v", r)
+		}
+	}()
+	buf := new(bytes.Buffer)
+	if err = tpl.Execute(buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (h *handler) writeDoc(ctx *frame.Context) {
+	doc, err := h.loadDoc(ctx)
+	if err != nil {
+		h.abortWithError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	config := h.config
+	if !config.DevMode {
+		cacheControl := "no-cache"
+		if config.SpecMaxAge > 0 {
+			cacheControl = fmt.Sprintf("public, max-age=%d", int(config.SpecMaxAge.Seconds()))
+			if len(config.BasicAuth) > 0 || config.AutoSchemes || config.TagAccess != nil || config.SpecVariant != nil || config.SpecProvider != nil {
+				// The spec is protected or may differ per caller, so only the browser may keep it;
+				// `public` would let shared caches serve it to anyone.
+				cacheControl = fmt.Sprintf("private, max-age=%d", int(config.SpecMaxAge.Seconds()))
+			}
+		}
+		ctx.Header("Cache-Control", cacheControl)
+	}
+	etag := specETag(doc, h.gzipResponse(ctx, doc))
+	ctx.Header("ETag", etag)
+	if etagMatches(string(ctx.GetHeader("If-None-Match")), etag) {
+		if config.Compress {
+			ctx.Header("Vary", "Accept-Encoding")
+		}
+		ctx.Status(http.StatusNotModified)
+		return
+	}
+	key := ""
+	if !config.AutoSchemes && config.TagAccess == nil && config.SpecVariant == nil && config.SpecProvider == nil {
+		// Only a spec that is the same for every request is worth keeping compressed; caching
+		// each view or provider document would grow the cache past MaxCachedSpecs.
+		key = contentKey("doc.json", doc)
+	}
+	h.writeBody(ctx, key, doc)
+}
+
+// specETag returns the strong ETag of the spec doc, which differs between the identity and the gzip encoding.
+func specETag(doc []byte, gzipped bool) string {
+	sum := sha256.Sum256(doc)
+	etag := hex.EncodeToString(sum[:16])
+	if gzipped {
+		etag += "-gzip"
+	}
+	return `"` + etag + `"`
+}
+
+// etagMatches reports whether the If-None-Match header value matches etag.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// gzipResponse reports whether writeBody gzip encodes data for the request.
+func (h *handler) gzipResponse(ctx *frame.Context, data []byte) bool {
+	return h.config.Compress && len(data) >= minCompressSize && acceptsGzip(ctx)
+}
+
+// writeBody writes data, gzip encoded when Compress is on and the client accepts it.
+// Compressed bodies are cached under key; an empty key compresses without caching.
+func (h *handler) writeBody(ctx *frame.Context, key string, data []byte) {
+	if h.config.Compress {
+		ctx.Header("Vary", "Accept-Encoding")
+		if h.gzipResponse(ctx, data) {
+			gz, err := h.gzipped.compress(h.config, key, data)
+			if err != nil {
+				h.abortWithError(ctx, http.StatusInternalServerError, err)
+				return
+			}
+			ctx.Header("Content-Encoding", "gzip")
+			data = gz
+		}
+	}
+	if _, err := ctx.Write(data); err != nil {
+		h.abortWithError(ctx, http.StatusInternalServerError, err)
+	}
+}
+
+func setContentType(ctx *frame.Context, config *Config, path string) {
+	switch filepath.Ext(path) {
+	case ".html":
+		ctx.Header("Content-Type", "text/html; charset=utf-8")
+	case ".css":
+		ctx.Header("Content-Type", "text/css; charset=utf-8")
+	case ".js":
+		ctx.Header("Content-Type", "application/javascript")
+	case ".png":
+		ctx.Header("Content-Type", "image/png")
+	case ".json":
+		ctx.Header("Content-Type", config.JSONContentType)
+	case ".map":
+		ctx.Header("Content-Type", "application/json")
+	case "":
+	default:
+		// Never leave a file without a Content-Type, or browsers start sniffing.
+		contentType := mime.TypeByExtension(filepath.Ext(path))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		ctx.Header("Content-Type", contentType)
+	}
+}
+
+// abortWithError aborts the request with code, exposing err in the body when DevMode is on.
+func (h *handler) abortWithError(ctx *frame.Context, code int, err error) {
+	message := http.StatusText(code)
+	if h.config.DevMode && err != nil {
+		message = err.Error()
+	}
+	if h.errorPage(ctx, code, message) {
+		return
+	}
+	if h.config.DevMode && err != nil {
+		ctx.AbortWithMsg(err.Error(), code)
+		return
+	}
+	ctx.AbortWithStatus(code)
+}
+
+// abortWithMessage answers the request with code and message.
+func (h *handler) abortWithMessage(ctx *frame.Context, code int, message string) {
+	if !h.errorPage(ctx, code, message) {
+		ctx.String(code, message)
+	}
+}
+
+// errorPageData is what Config.ErrorTemplate is executed with.
+type errorPageData struct {
+	Status     int
+	StatusText string
+	Message    string
+	Title      string
+}
+
+// errorPage answers the request with the page rendered from Config.ErrorTemplate, reporting
+// whether it did. Without an ErrorTemplate, or if it fails, the caller answers in plain text.
+func (h *handler) errorPage(ctx *frame.Context, code int, message string) bool {
+	if h.errorTpl == nil {
+		return false
+	}
+	page, err := renderTemplate(h.errorTpl, errorPageData{
+		Status:     code,
+		StatusText: http.StatusText(code),
+		Message:    message,
+		Title:      h.config.Title,
+	})
+	if err != nil {
+		log.Error().Str("log_service", "Swagger").Msgf("[Swagger] rendering the error page: %v", err)
+		return false
+	}
+	ctx.Data(code, "text/html; charset=utf-8", page)
+	ctx.Abort()
+	return true
+}
+
+// docsIndex is served as index.json, telling tools how to fetch the spec without parsing the page.
+// Source is `provider`, `file` or `instance`; a SpecFile is not named, so its path stays private.
+type docsIndex struct {
+	Source     string            `json:"source"`
+	Instance   string            `json:"instance,omitempty"`
+	Title      string            `json:"title"`
+	UIVersion  string            `json:"uiVersion"`
+	Spec       string            `json:"spec"`
+	Formats    map[string]string `json:"formats"`
+	Operations string            `json:"operations"`
+}
+
+func newDocsIndex(config *Config) docsIndex {
+	index := docsIndex{
+		Title:      config.Title,
+		UIVersion:  SwaggerUIVersion,
+		Spec:       config.URL,
+		Formats:    map[string]string{"json": config.URL},
+		Operations: "operations.json",
+	}
+	switch {
+	case config.SpecProvider != nil:
+		index.Source = "provider"
+	case config.SpecFile != "":
+		index.Source = "file"
+	default:
+		index.Source = "instance"
+		index.Instance = docInstance(config)
+	}
+	return index
+}
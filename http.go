@@ -0,0 +1,61 @@
+package swagger
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/oarkflow/frame"
+	"github.com/oarkflow/frame/pkg/common/adaptor"
+	"github.com/oarkflow/frame/pkg/network"
+	"github.com/oarkflow/frame/pkg/route/param"
+)
+
+// HTTPHandler adapts a handler returned by New, NewReDoc or NewSpecHandler to net/http, as if it
+// were registered at `mount + "*any"`. It makes the handler easy to exercise with net/http/httptest:
+//
+//	h := swagger.HTTPHandler("/swagger/", swagger.New())
+//	rec := httptest.NewRecorder()
+//	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/swagger/doc.json", nil))
+//	// assert on rec.Code, rec.Header() and rec.Body
+func HTTPHandler(mount string, handler frame.HandlerFunc) http.Handler {
+	if !strings.HasSuffix(mount, "/") {
+		mount += "/"
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := frame.NewContext(1)
+		if err := adaptor.CopyToFrameRequest(r, &ctx.Request); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if r.TLS != nil {
+			ctx.Request.URI().SetScheme("https")
+		}
+		if addr, err := netip.ParseAddrPort(r.RemoteAddr); err == nil {
+			ctx.SetConn(remoteConn{addr: net.TCPAddrFromAddrPort(addr)})
+		}
+		path := strings.TrimPrefix(r.URL.Path, mount)
+		if r.URL.Path+"/" == mount {
+			path = ""
+		}
+		ctx.Params = append(ctx.Params, param.Param{Key: "any", Value: path})
+
+		handler(r.Context(), ctx)
+
+		ctx.Response.Header.VisitAll(func(k, v []byte) {
+			w.Header().Add(string(k), string(v))
+		})
+		w.WriteHeader(ctx.Response.StatusCode())
+		_, _ = w.Write(ctx.Response.Body())
+	})
+}
+
+// remoteConn stands in for the connection of a net/http request, so ClientIP and
+// Config.RateLimit see the client address. It only answers RemoteAddr.
+type remoteConn struct {
+	network.Conn
+	addr net.Addr
+}
+
+func (c remoteConn) RemoteAddr() net.Addr { return c.addr }
@@ -0,0 +1,73 @@
+package swagger
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimit limits how many requests a single client IP can make per Interval.
+type RateLimit struct {
+	Requests int
+	Interval time.Duration
+}
+
+// rateLimiter is an in-memory token bucket per client IP.
+type rateLimiter struct {
+	capacity  float64
+	perSecond float64
+	interval  time.Duration
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(limit *RateLimit) *rateLimiter {
+	return &rateLimiter{
+		capacity:  float64(limit.Requests),
+		perSecond: float64(limit.Requests) / limit.Interval.Seconds(),
+		interval:  limit.Interval,
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// allow takes a token from the bucket of ip. When there is none left it
+// returns false along with how long until the next token is available.
+func (l *rateLimiter) allow(ip string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) > l.interval {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[ip]
+	if !ok {
+		b = &bucket{tokens: l.capacity, last: now}
+		l.buckets[ip] = b
+	}
+	b.tokens = math.Min(l.capacity, b.tokens+now.Sub(b.last).Seconds()*l.perSecond)
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.perSecond * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// sweep forgets buckets that have refilled completely, keeping the map bounded by active clients.
+func (l *rateLimiter) sweep(now time.Time) {
+	for ip, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.perSecond >= l.capacity {
+			delete(l.buckets, ip)
+		}
+	}
+	l.lastSweep = now
+}
@@ -0,0 +1,33 @@
+package swagger
+
+import "github.com/oarkflow/frame"
+
+// NewReDoc is like New but renders the spec with ReDoc instead of Swagger UI.
+// doc.json and the assets are served the same way as by New. The page loads a pinned
+// ReDoc release from cdn.redoc.ly, so an upstream release never changes it unnoticed.
+func NewReDoc(cfg ...*Config) frame.HandlerFunc {
+	return newHandler("redoc_index.html", redocIndexTpl, cfg...).serve
+}
+
+const redocIndexTpl = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="UTF-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <title>{{.Title}}</title>
+  <link href="https://fonts.googleapis.com/css?family=Montserrat:300,400,700|Roboto:300,400,700" rel="stylesheet">
+  <link rel="icon" type="image/png" href="{{.AssetPrefix}}favicon-32x32.png" sizes="32x32" />
+  <link rel="icon" type="image/png" href="{{.AssetPrefix}}favicon-16x16.png" sizes="16x16" />
+  <style>
+    body {
+      margin: 0;
+      padding: 0;
+    }
+  </style>
+</head>
+<body>
+<redoc spec-url="{{.URL}}"></redoc>
+<script src="https://cdn.redoc.ly/redoc/v2.1.5/bundles/redoc.standalone.js"> </script>
+</body>
+</html>
+`
@@ -0,0 +1,128 @@
+package swagger
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/oarkflow/frame"
+	"github.com/oarkflow/log"
+)
+
+// Renderers for Config.Renderers.
+const (
+	RendererSwaggerUI = "swaggerui"
+	RendererReDoc     = "redoc"
+	RendererRapiDoc   = "rapidoc"
+)
+
+// rendererTitles names the renderers on the landing page.
+var rendererTitles = map[string]string{
+	RendererSwaggerUI: "Swagger UI",
+	RendererReDoc:     "ReDoc",
+	RendererRapiDoc:   "RapiDoc",
+}
+
+// newRenderers parses the pages of the renderers other than Swagger UI, which is index.html, by name.
+func newRenderers(names []string) map[string]*template.Template {
+	renderers := map[string]*template.Template{}
+	for _, name := range names {
+		switch name {
+		case RendererReDoc:
+			renderers[name] = template.Must(template.New("redoc_index.html").Parse(redocIndexTpl))
+		case RendererRapiDoc:
+			renderers[name] = template.Must(template.New("rapidoc_index.html").Parse(rapidocIndexTpl))
+		}
+	}
+	return renderers
+}
+
+// landingLink is a renderer listed on the landing page.
+type landingLink struct {
+	Title string
+	Href  string
+}
+
+var landingTpl = template.Must(template.New("landing.html").Parse(landingIndexTpl))
+
+// writeLanding renders the page at the bare mount linking to each of Config.Renderers.
+func (h *handler) writeLanding(ctx *frame.Context) {
+	mount := h.mountPath(string(ctx.Path()))
+	data := struct {
+		Title string
+		Links []landingLink
+	}{Title: h.config.Title}
+	for _, name := range h.config.Renderers {
+		title, ok := rendererTitles[name]
+		if !ok {
+			continue
+		}
+		href := mount + name
+		if name == RendererSwaggerUI {
+			href = mount + "index.html"
+		}
+		data.Links = append(data.Links, landingLink{Title: title, Href: href})
+	}
+	page, err := renderTemplate(landingTpl, data)
+	if err != nil {
+		log.Error().Str("log_service", "Swagger").Msgf("[Swagger] rendering landing.html: %v", err)
+		h.abortWithError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	if h.csp != "" {
+		ctx.Header("Content-Security-Policy", h.csp)
+	}
+	ctx.Header("Content-Type", "text/html; charset=utf-8")
+	h.writeBody(ctx, "", page)
+}
+
+const rapidocIndexTpl = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="UTF-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <title>{{.Title}}</title>
+  <link rel="icon" type="image/png" href="{{.AssetPrefix}}favicon-32x32.png" sizes="32x32" />
+  <link rel="icon" type="image/png" href="{{.AssetPrefix}}favicon-16x16.png" sizes="16x16" />
+  <script type="module" src="https://unpkg.com/rapidoc@9.3.8/dist/rapidoc-min.js"></script>
+</head>
+<body>
+<rapi-doc spec-url="{{.URL}}" render-style="read"{{if .TryItOutDisabled}} allow-try="false"{{end}}></rapi-doc>
+</body>
+</html>
+`
+
+const landingIndexTpl = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="UTF-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <title>{{.Title}}</title>
+  <style>
+    body {
+      margin: 40px auto;
+      max-width: 640px;
+      font-family: sans-serif;
+      color: #3b4151;
+    }
+    a {
+      display: block;
+      margin: 12px 0;
+      padding: 16px 20px;
+      border: 1px solid #d8dde7;
+      border-radius: 4px;
+      color: inherit;
+      text-decoration: none;
+    }
+    a:hover {
+      background: #f7f7f7;
+    }
+  </style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{- range .Links}}
+<a href="{{.Href}}">{{.Title}}</a>
+{{- end}}
+</body>
+</html>
+`
@@ -0,0 +1,909 @@
+package swagger
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oarkflow/frame"
+	"github.com/swaggo/swag"
+)
+
+// specWatchInterval is how often a watched SpecFile is polled where the file system
+// can't notify of changes.
+var specWatchInterval = time.Second
+
+// readDoc returns the spec document, either from the SpecFile or from the swag instance,
+// the first of InstanceNames that is registered if they are set.
+func readDoc(config *Config, spec *specFile) ([]byte, error) {
+	if spec != nil {
+		if config.DevMode {
+			return spec.load()
+		}
+		return spec.read()
+	}
+	if len(config.InstanceNames) == 0 {
+		doc, err := swag.ReadDoc(config.InstanceName)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(doc), nil
+	}
+	var err error
+	for _, name := range config.InstanceNames {
+		var doc string
+		if doc, err = swag.ReadDoc(name); err == nil {
+			return []byte(doc), nil
+		}
+	}
+	return nil, fmt.Errorf("none of the swag instances %q: %w", config.InstanceNames, err)
+}
+
+// docInstance returns the name of the swag instance readDoc reads: InstanceName, or the first
+// registered one of InstanceNames, or the first of them if none is registered yet.
+func docInstance(config *Config) string {
+	if len(config.InstanceNames) == 0 {
+		return config.InstanceName
+	}
+	for _, name := range config.InstanceNames {
+		if swag.GetSwagger(name) != nil {
+			return name
+		}
+	}
+	return config.InstanceNames[0]
+}
+
+// readVariant returns the spec document of the swag instance registered for variant in SpecVariants.
+func readVariant(config *Config, variant string) ([]byte, error) {
+	doc, err := swag.ReadDoc(config.SpecVariants[variant])
+	if err != nil {
+		return nil, err
+	}
+	return []byte(doc), nil
+}
+
+// loadDoc returns the spec document as served in doc.json for the request in ctx.
+func (h *handler) loadDoc(ctx *frame.Context) ([]byte, error) {
+	config := h.config
+	variant := ""
+	if config.SpecVariant != nil && config.SpecProvider == nil {
+		if v := config.SpecVariant(ctx); config.SpecVariants[v] != "" {
+			variant = v
+		}
+	}
+	var source []byte
+	var err error
+	switch {
+	case config.SpecProvider != nil:
+		source, err = config.SpecProvider(ctx)
+		// The provider may answer each request differently, so only its last document is
+		// kept: get compares the source, and put replaces the entry of an older one.
+		variant = "provider"
+	case variant == "":
+		source, err = readDoc(config, h.spec)
+	default:
+		source, err = readVariant(config, variant)
+	}
+	if err != nil {
+		return nil, err
+	}
+	key := transformSignature(config) + "\x00" + variant
+	entry, ok := h.docs.get(config, key, source)
+	if !ok {
+		doc, err := processDoc(config, source)
+		if err != nil {
+			return nil, err
+		}
+		var tags []string
+		if config.TagAccess != nil {
+			if tags, err = specTags(doc); err != nil {
+				return nil, err
+			}
+		}
+		entry = h.docs.put(config, key, &docEntry{source: source, processed: doc, tags: tags})
+	}
+	if !config.AutoSchemes && config.TagAccess == nil {
+		return entry.processed, nil
+	}
+
+	// The rest depends on the request, so the result is cached under
+	// the attributes it was derived from: the scheme and the allowed tags.
+	var view strings.Builder
+	scheme := ""
+	if config.AutoSchemes {
+		scheme = requestScheme(ctx)
+		view.WriteString(scheme)
+	}
+	allowed := map[string]bool{}
+	if config.TagAccess != nil {
+		for _, tag := range entry.tags {
+			ok := config.TagAccess(ctx, tag)
+			allowed[tag] = ok
+			if ok {
+				view.WriteString("\x00" + tag)
+			}
+		}
+	}
+	if doc, ok := h.docs.view(config, entry, view.String()); ok {
+		return doc, nil
+	}
+	doc := entry.processed
+	if config.AutoSchemes {
+		if doc, err = setSchemes(doc, scheme); err != nil {
+			return nil, err
+		}
+	}
+	if config.TagAccess != nil {
+		allow := func(tag string) bool {
+			ok, seen := allowed[tag]
+			if !seen {
+				ok = config.TagAccess(ctx, tag)
+				allowed[tag] = ok
+			}
+			return ok
+		}
+		if doc, err = filterTags(doc, allow); err != nil {
+			return nil, err
+		}
+	}
+	h.docs.putView(config, entry, view.String(), doc)
+	return doc, nil
+}
+
+// transformSignature describes the transformations processDoc applies under config, so cached
+// specs are told apart, and go stale, when the configuration behind them changes.
+func transformSignature(config *Config) string {
+	var postLoad uintptr
+	if config.PostLoad != nil {
+		postLoad = reflect.ValueOf(config.PostLoad).Pointer()
+	}
+	maintenance := ""
+	if config.MaintenanceMode {
+		maintenance = config.MaintenanceMessage
+	}
+	return fmt.Sprintf("%t|%x|%q|%v|%q|%t|%t|%q|%t|%q",
+		config.StripBOM, postLoad, config.PathPrefixFilter, config.OAuthEndpoints,
+		config.DefaultParameterSerialization, config.DereferenceRefs, config.MaintenanceMode, maintenance,
+		config.StableSpecOrdering, config.SpecLineEndings)
+}
+
+// specTags lists the tags TagAccess is asked about for doc: those of its operations, with
+// untagged operations as the `default` tag, and those declared at the top level.
+func specTags(doc []byte) ([]string, error) {
+	spec, err := decodeSpec(doc)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	paths, _ := spec["paths"].(map[string]interface{})
+	for _, item := range paths {
+		item, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, method := range operationMethods {
+			op, ok := item[method].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			tags, _ := op["tags"].([]interface{})
+			if len(tags) == 0 {
+				seen[untaggedTag] = true
+			}
+			for _, tag := range tags {
+				if name, ok := tag.(string); ok {
+					seen[name] = true
+				}
+			}
+		}
+	}
+	tags, _ := spec["tags"].([]interface{})
+	for _, tag := range tags {
+		if t, ok := tag.(map[string]interface{}); ok {
+			if name, ok := t["name"].(string); ok {
+				seen[name] = true
+			}
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// processDoc applies StripBOM, PostLoad and the configured transformations that don't depend on the request.
+func processDoc(config *Config, doc []byte) ([]byte, error) {
+	var err error
+	if config.StripBOM {
+		doc = bytes.TrimPrefix(doc, utf8BOM)
+	}
+	if config.PostLoad != nil {
+		if doc, err = config.PostLoad(doc); err != nil {
+			return nil, err
+		}
+	}
+	if config.PathPrefixFilter != "" {
+		if doc, err = filterPaths(doc, config.PathPrefixFilter); err != nil {
+			return nil, err
+		}
+	}
+	if len(config.OAuthEndpoints) > 0 {
+		if doc, err = rewriteOAuthEndpoints(doc, config.OAuthEndpoints); err != nil {
+			return nil, err
+		}
+	}
+	if validCollectionFormat(config.DefaultParameterSerialization) {
+		if doc, err = setParameterSerialization(doc, config.DefaultParameterSerialization); err != nil {
+			return nil, err
+		}
+	}
+	if config.DereferenceRefs {
+		if doc, err = dereference(doc); err != nil {
+			return nil, err
+		}
+	}
+	if config.MaintenanceMode {
+		if doc, err = annotateMaintenance(doc, config.MaintenanceMessage); err != nil {
+			return nil, err
+		}
+	}
+	if config.StableSpecOrdering {
+		if doc, err = sortSpec(doc); err != nil {
+			return nil, err
+		}
+	}
+	if config.SpecLineEndings != "" {
+		doc = normalizeLineEndings(doc, config.SpecLineEndings)
+	}
+	return doc, nil
+}
+
+// utf8BOM is the byte order mark some Windows editors put at the start of UTF-8 files.
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// normalizeLineEndings converts the line endings of doc to LineEndingsLF or LineEndingsCRLF.
+func normalizeLineEndings(doc []byte, endings string) []byte {
+	doc = bytes.ReplaceAll(doc, []byte("\r\n"), []byte("\n"))
+	if endings == LineEndingsCRLF {
+		doc = bytes.ReplaceAll(doc, []byte("\n"), []byte("\r\n"))
+	}
+	return doc
+}
+
+// decodeSpec decodes a JSON spec document, keeping numbers as written.
+func decodeSpec(doc []byte) (map[string]interface{}, error) {
+	var spec map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+	if err := dec.Decode(&spec); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// requestScheme returns the protocol the client used, honouring X-Forwarded-Proto set by proxies.
+func requestScheme(ctx *frame.Context) string {
+	// The header may come from the client, so nothing but http or https is taken from it.
+	proto := strings.Split(ctx.Request.Header.Get("X-Forwarded-Proto"), ",")[0]
+	if proto = strings.ToLower(strings.TrimSpace(proto)); proto == "http" || proto == "https" {
+		return proto
+	}
+	if scheme := string(ctx.URI().Scheme()); scheme != "" {
+		return scheme
+	}
+	return "http"
+}
+
+// setSchemes replaces the `schemes` of a Swagger 2.0 document. OpenAPI 3 documents have none and are returned as is.
+func setSchemes(doc []byte, scheme string) ([]byte, error) {
+	spec, err := decodeSpec(doc)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := spec["swagger"]; !ok {
+		return doc, nil
+	}
+	spec["schemes"] = []string{scheme}
+	return encodeSpec(spec)
+}
+
+// openAPIStyles maps Swagger 2.0 collection formats to OpenAPI 3 style and explode. OpenAPI 3
+// has no tab delimited style, so OpenAPI 3 parameters are left as they are for `tsv`.
+var openAPIStyles = map[string]struct {
+	style   string
+	explode bool
+}{
+	"csv":   {"form", false},
+	"multi": {"form", true},
+	"ssv":   {"spaceDelimited", false},
+	"pipes": {"pipeDelimited", false},
+}
+
+// validCollectionFormat reports whether format is a Swagger 2.0 collection format.
+func validCollectionFormat(format string) bool {
+	_, ok := openAPIStyles[format]
+	return ok || format == "tsv"
+}
+
+// setParameterSerialization gives array query parameters that don't define how they are
+// serialized the collection format `format`, or its OpenAPI 3 style, so Try it out encodes
+// them the way the API expects. Parameters behind a `$ref` are set where they are defined.
+func setParameterSerialization(doc []byte, format string) ([]byte, error) {
+	spec, err := decodeSpec(doc)
+	if err != nil {
+		return nil, err
+	}
+	setParams := func(params interface{}) {
+		var list []interface{}
+		switch p := params.(type) {
+		case []interface{}:
+			list = p
+		case map[string]interface{}:
+			for _, param := range p {
+				list = append(list, param)
+			}
+		}
+		for _, param := range list {
+			if param, ok := param.(map[string]interface{}); ok && param["in"] == "query" {
+				setSerialization(param, format)
+			}
+		}
+	}
+	setParams(spec["parameters"])
+	if components, ok := spec["components"].(map[string]interface{}); ok {
+		setParams(components["parameters"])
+	}
+	paths, _ := spec["paths"].(map[string]interface{})
+	for _, item := range paths {
+		item, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		setParams(item["parameters"])
+		for _, method := range operationMethods {
+			if op, ok := item[method].(map[string]interface{}); ok {
+				setParams(op["parameters"])
+			}
+		}
+	}
+	return encodeSpec(spec)
+}
+
+func setSerialization(param map[string]interface{}, format string) {
+	if param["type"] == "array" {
+		if _, ok := param["collectionFormat"]; !ok {
+			param["collectionFormat"] = format
+		}
+		return
+	}
+	schema, _ := param["schema"].(map[string]interface{})
+	if schema["type"] != "array" {
+		return
+	}
+	if _, ok := param["style"]; ok {
+		return
+	}
+	if s, ok := openAPIStyles[format]; ok {
+		param["style"] = s.style
+		param["explode"] = s.explode
+	}
+}
+
+// filterPaths drops the paths that don't start with prefix.
+func filterPaths(doc []byte, prefix string) ([]byte, error) {
+	spec, err := decodeSpec(doc)
+	if err != nil {
+		return nil, err
+	}
+	paths, _ := spec["paths"].(map[string]interface{})
+	for path := range paths {
+		if !strings.HasPrefix(path, prefix) {
+			delete(paths, path)
+		}
+	}
+	return encodeSpec(spec)
+}
+
+// annotateMaintenance puts message at the top of the spec description, which Swagger UI shows above the operations.
+func annotateMaintenance(doc []byte, message string) ([]byte, error) {
+	spec, err := decodeSpec(doc)
+	if err != nil {
+		return nil, err
+	}
+	info, ok := spec["info"].(map[string]interface{})
+	if !ok {
+		info = map[string]interface{}{}
+		spec["info"] = info
+	}
+	banner := "> **" + message + "**"
+	if description, _ := info["description"].(string); description != "" {
+		banner += "\n\n" + description
+	}
+	info["description"] = banner
+	return encodeSpec(spec)
+}
+
+// untaggedTag is the tag Swagger UI lists operations without tags under.
+const untaggedTag = "default"
+
+// filterTags drops the operations none of whose tags are allowed, the paths left without
+// operations and the disallowed entries of the top-level `tags`.
+func filterTags(doc []byte, allow func(tag string) bool) ([]byte, error) {
+	spec, err := decodeSpec(doc)
+	if err != nil {
+		return nil, err
+	}
+	paths, _ := spec["paths"].(map[string]interface{})
+	for path, item := range paths {
+		item, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		remaining := 0
+		for _, method := range operationMethods {
+			op, ok := item[method].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if operationAllowed(op, allow) {
+				remaining++
+			} else {
+				delete(item, method)
+			}
+		}
+		if remaining == 0 {
+			delete(paths, path)
+		}
+	}
+	if tags, ok := spec["tags"].([]interface{}); ok {
+		kept := tags[:0]
+		for _, tag := range tags {
+			if t, ok := tag.(map[string]interface{}); ok {
+				if name, _ := t["name"].(string); !allow(name) {
+					continue
+				}
+			}
+			kept = append(kept, tag)
+		}
+		spec["tags"] = kept
+	}
+	return encodeSpec(spec)
+}
+
+func operationAllowed(op map[string]interface{}, allow func(tag string) bool) bool {
+	tags, _ := op["tags"].([]interface{})
+	if len(tags) == 0 {
+		return allow(untaggedTag)
+	}
+	for _, tag := range tags {
+		if name, ok := tag.(string); ok && allow(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// OAuthEndpoint overrides the URLs of an OAuth2 security scheme. Empty fields are left unchanged.
+type OAuthEndpoint struct {
+	AuthorizationURL string
+	TokenURL         string
+	RefreshURL       string
+}
+
+// rewriteOAuthEndpoints applies endpoints, keyed by security scheme name, to both
+// Swagger 2.0 `securityDefinitions` and OpenAPI 3 `components.securitySchemes`.
+func rewriteOAuthEndpoints(doc []byte, endpoints map[string]OAuthEndpoint) ([]byte, error) {
+	spec, err := decodeSpec(doc)
+	if err != nil {
+		return nil, err
+	}
+	definitions, _ := spec["securityDefinitions"].(map[string]interface{})
+	schemes := map[string]interface{}{}
+	if components, ok := spec["components"].(map[string]interface{}); ok {
+		schemes, _ = components["securitySchemes"].(map[string]interface{})
+	}
+	for name, endpoint := range endpoints {
+		if scheme, ok := definitions[name].(map[string]interface{}); ok {
+			endpoint.apply(scheme)
+		}
+		if scheme, ok := schemes[name].(map[string]interface{}); ok {
+			flows, _ := scheme["flows"].(map[string]interface{})
+			for _, flow := range flows {
+				if flow, ok := flow.(map[string]interface{}); ok {
+					endpoint.apply(flow)
+				}
+			}
+		}
+	}
+	return encodeSpec(spec)
+}
+
+// apply sets the URLs present in an OAuth2 scheme or flow object.
+func (e OAuthEndpoint) apply(obj map[string]interface{}) {
+	for key, value := range map[string]string{
+		"authorizationUrl": e.AuthorizationURL,
+		"tokenUrl":         e.TokenURL,
+		"refreshUrl":       e.RefreshURL,
+	} {
+		if _, ok := obj[key]; ok && value != "" {
+			obj[key] = value
+		}
+	}
+}
+
+// encodeSpec encodes a decoded spec document. Object keys come out sorted, so the
+// output is deterministic, and HTML characters are kept as written.
+func encodeSpec(spec interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(spec); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// sortSpec re-encodes a spec document with its object keys sorted.
+func sortSpec(doc []byte) ([]byte, error) {
+	spec, err := decodeSpec(doc)
+	if err != nil {
+		return nil, err
+	}
+	return encodeSpec(spec)
+}
+
+// dereference replaces every internal `$ref` with the value it points to. A `$ref` met again while
+// it is being expanded is circular, and one that points nowhere is dangling; both are kept as is.
+func dereference(doc []byte) ([]byte, error) {
+	spec, err := decodeSpec(doc)
+	if err != nil {
+		return nil, err
+	}
+	return encodeSpec(resolveRefs(spec, spec, map[string]bool{}))
+}
+
+func resolveRefs(root map[string]interface{}, node interface{}, expanding map[string]bool) interface{} {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		if ref, ok := v["$ref"].(string); ok && strings.HasPrefix(ref, "#/") {
+			target, ok := lookupRef(root, ref)
+			if !ok || expanding[ref] {
+				return v
+			}
+			expanding[ref] = true
+			defer delete(expanding, ref)
+			return resolveRefs(root, target, expanding)
+		}
+		out := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			out[key] = resolveRefs(root, value, expanding)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, value := range v {
+			out[i] = resolveRefs(root, value, expanding)
+		}
+		return out
+	}
+	return node
+}
+
+// lookupRef resolves an internal JSON pointer such as `#/definitions/Pet` or
+// `#/paths/~1pets/get/parameters/0`, reporting whether it points to a value.
+func lookupRef(root map[string]interface{}, ref string) (interface{}, bool) {
+	var node interface{} = root
+	for _, token := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		switch v := node.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if node, ok = v[token]; !ok {
+				return nil, false
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(v) || token != strconv.Itoa(i) {
+				return nil, false
+			}
+			node = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return node, true
+}
+
+// docCache holds the result of processDoc for the last source document of each spec variant
+// and transformation signature, so it only runs again once the source, from the swag instance
+// or the SpecFile, or the configuration changes. It also keeps the request dependent views
+// derived from each. With Config.MaxCachedSpecs set, the least recently used documents,
+// processed or views, are evicted beyond that many.
+type docCache struct {
+	mu       sync.Mutex
+	items    map[string]*list.Element
+	lru      list.List // of *docItem, most recently used first
+	nextID   uint64
+	counters cacheCounters
+}
+
+// docItem is a cached document: a processed entry, or a view of one.
+type docItem struct {
+	key    string
+	entry  *docEntry
+	view   []byte
+	parent *docEntry
+}
+
+// docEntry is a source document, its processed form and the tags it has.
+type docEntry struct {
+	id        uint64
+	key       string
+	source    []byte
+	processed []byte
+	tags      []string
+	views     map[string]struct{}
+}
+
+func (d *docCache) get(config *Config, key string, source []byte) (*docEntry, bool) {
+	if config.DevMode || config.LowMemoryMode {
+		return nil, false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	el, ok := d.items[key]
+	ok = ok && bytes.Equal(el.Value.(*docItem).entry.source, source)
+	d.counters.record(ok)
+	if !ok {
+		return nil, false
+	}
+	d.lru.MoveToFront(el)
+	return el.Value.(*docItem).entry, true
+}
+
+// put stores entry under key, replacing the entry for an older source along with its views.
+func (d *docCache) put(config *Config, key string, entry *docEntry) *docEntry {
+	if config.DevMode || config.LowMemoryMode {
+		return entry
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.items == nil {
+		d.items = make(map[string]*list.Element)
+	}
+	if el, ok := d.items[key]; ok {
+		d.remove(el)
+	}
+	d.nextID++
+	entry.id, entry.key = d.nextID, key
+	d.items[key] = d.lru.PushFront(&docItem{key: key, entry: entry})
+	d.evict(config)
+	return entry
+}
+
+// viewKey keys the view of entry for the request attributes in attrs.
+func viewKey(entry *docEntry, attrs string) string {
+	return strconv.FormatUint(entry.id, 10) + "\x01" + attrs
+}
+
+// view returns the document derived from entry for the request attributes in attrs.
+func (d *docCache) view(config *Config, entry *docEntry, attrs string) ([]byte, bool) {
+	if config.DevMode || config.LowMemoryMode {
+		return nil, false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	el, ok := d.items[viewKey(entry, attrs)]
+	if !ok {
+		return nil, false
+	}
+	// A view in use keeps the entry it derives from, and goes with, in use too.
+	if parent, ok := d.items[entry.key]; ok {
+		d.lru.MoveToFront(parent)
+	}
+	d.lru.MoveToFront(el)
+	return el.Value.(*docItem).view, true
+}
+
+func (d *docCache) putView(config *Config, entry *docEntry, attrs string, doc []byte) {
+	if config.DevMode || config.LowMemoryMode {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// Skip entries replaced or evicted meanwhile, whose views could never be found again.
+	if el, ok := d.items[entry.key]; !ok || el.Value.(*docItem).entry != entry {
+		return
+	}
+	key := viewKey(entry, attrs)
+	if el, ok := d.items[key]; ok {
+		d.remove(el)
+	}
+	d.items[key] = d.lru.PushFront(&docItem{key: key, view: doc, parent: entry})
+	if entry.views == nil {
+		entry.views = make(map[string]struct{})
+	}
+	entry.views[key] = struct{}{}
+	d.evict(config)
+}
+
+// evict drops the least recently used documents beyond MaxCachedSpecs.
+func (d *docCache) evict(config *Config) {
+	for config.MaxCachedSpecs > 0 && d.lru.Len() > config.MaxCachedSpecs {
+		d.remove(d.lru.Back())
+	}
+}
+
+// remove drops the document in el, and the views of an entry with it.
+func (d *docCache) remove(el *list.Element) {
+	item := d.lru.Remove(el).(*docItem)
+	delete(d.items, item.key)
+	if item.parent != nil {
+		delete(item.parent.views, item.key)
+		return
+	}
+	for key := range item.entry.views {
+		if view, ok := d.items[key]; ok {
+			d.lru.Remove(view)
+			delete(d.items, key)
+		}
+	}
+}
+
+// reset empties the cache.
+func (d *docCache) reset() {
+	d.mu.Lock()
+	d.items = nil
+	d.lru.Init()
+	d.mu.Unlock()
+}
+
+// operation is an entry of operations.json.
+type operation struct {
+	Method      string   `json:"method"`
+	Path        string   `json:"path"`
+	OperationID string   `json:"operationId,omitempty"`
+	Summary     string   `json:"summary,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+var operationMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// listOperations flattens the paths of a spec document into operations sorted by path.
+func listOperations(doc []byte) ([]operation, error) {
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(doc, &spec); err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(spec.Paths))
+	for path := range spec.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	ops := make([]operation, 0, len(paths))
+	for _, path := range paths {
+		for _, method := range operationMethods {
+			raw, ok := spec.Paths[path][method]
+			if !ok {
+				continue
+			}
+			op := operation{Method: strings.ToUpper(method), Path: path}
+			if err := json.Unmarshal(raw, &op); err != nil {
+				return nil, err
+			}
+			ops = append(ops, op)
+		}
+	}
+	return ops, nil
+}
+
+// specFile serves a spec document from disk. It is the value held by the handler, while
+// the watcher only holds the cache, so once the handler is discarded its finalizer stops
+// the watcher; close stops it sooner.
+type specFile struct {
+	*specFileCache
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// specFileCache holds the last read contents of a spec file.
+type specFileCache struct {
+	path    string
+	mu      sync.RWMutex
+	doc     []byte
+	modTime time.Time
+}
+
+func newSpecFile(path string, watch bool) *specFile {
+	f := &specFile{specFileCache: &specFileCache{path: path}}
+	if watch {
+		f.stop = make(chan struct{})
+		f.specFileCache.watch(f.stop)
+		runtime.SetFinalizer(f, (*specFile).close)
+	}
+	return f
+}
+
+// close stops watching the file. The cached document can still be read.
+func (f *specFile) close() {
+	if f.stop != nil {
+		f.stopOnce.Do(func() { close(f.stop) })
+	}
+}
+
+// read returns the cached document, loading it from disk on first use.
+func (s *specFileCache) read() ([]byte, error) {
+	s.mu.RLock()
+	doc := s.doc
+	s.mu.RUnlock()
+	if doc != nil {
+		return doc, nil
+	}
+	return s.load()
+}
+
+// reset drops the cached document so the next read loads it from disk.
+func (s *specFileCache) reset() {
+	s.mu.Lock()
+	s.doc = nil
+	s.mu.Unlock()
+}
+
+func (s *specFileCache) load() ([]byte, error) {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.doc = doc
+	s.modTime = info.ModTime()
+	s.mu.Unlock()
+	return doc, nil
+}
+
+// reloadIfChanged reloads a document already read once its modification time changes.
+func (s *specFileCache) reloadIfChanged() {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return
+	}
+	s.mu.RLock()
+	changed := s.doc != nil && !info.ModTime().Equal(s.modTime)
+	s.mu.RUnlock()
+	if changed {
+		_, _ = s.load()
+	}
+}
+
+// poll checks the file every interval until stop is closed. It watches the file where
+// the file system can't notify of changes.
+func (s *specFileCache) poll(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.reloadIfChanged()
+		}
+	}
+}
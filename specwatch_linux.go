@@ -0,0 +1,49 @@
+package swagger
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// specWatchEvents are the inotify events on the directory of a spec file that may replace
+// its contents: a write finishing, a file renamed into place and a touch.
+const specWatchEvents = syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO | syscall.IN_ATTRIB
+
+// watch starts reloading the file whenever inotify reports a change in its directory, until
+// stop is closed. The directory is watched rather than the file so a file replaced by a rename,
+// as editors and generators do, is still followed. The watch is in place once watch returns;
+// without inotify the file is polled instead.
+func (s *specFileCache) watch(stop <-chan struct{}) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		go s.poll(specWatchInterval, stop)
+		return
+	}
+	if _, err = syscall.InotifyAddWatch(fd, filepath.Dir(s.path), specWatchEvents); err != nil {
+		syscall.Close(fd)
+		go s.poll(specWatchInterval, stop)
+		return
+	}
+	// A non-blocking descriptor is served by the runtime poller, so closing the file ends a
+	// pending Read.
+	events := os.NewFile(uintptr(fd), "inotify")
+	go func() {
+		<-stop
+		events.Close()
+	}()
+	go s.readEvents(events)
+}
+
+// readEvents reloads the file on each batch of events read from events, until it is closed.
+func (s *specFileCache) readEvents(events *os.File) {
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		if _, err := events.Read(buf); err != nil {
+			return
+		}
+		// The events may be for other files of the directory; those leave the modification
+		// time of the spec file as it was.
+		s.reloadIfChanged()
+	}
+}
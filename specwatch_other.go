@@ -0,0 +1,8 @@
+//go:build !linux
+
+package swagger
+
+// watch starts polling the file until stop is closed.
+func (s *specFileCache) watch(stop <-chan struct{}) {
+	go s.poll(specWatchInterval, stop)
+}
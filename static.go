@@ -0,0 +1,96 @@
+package swagger
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/oarkflow/frame"
+)
+
+// GenerateStaticSite writes index.html, doc.json and the assets to outDir, so the docs can be hosted
+// as static files, e.g. on a CDN. Options that depend on the request, such as AutoSchemes, TagAccess
+// and BasicAuth, don't apply, and AllowedAssets limits the assets written. A nil cfg uses the defaults.
+func GenerateStaticSite(cfg *Config, outDir string) error {
+	config := prepareConfig(cfg)
+	if err := config.validate(); err != nil {
+		return err
+	}
+	h := newHandler("swagger_index.html", swaggerIndexTpl, config)
+	defer h.close()
+	if h.indexErr != nil {
+		return fmt.Errorf("parsing index.html: %w", h.indexErr)
+	}
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		return err
+	}
+
+	page, err := renderTemplate(h.index, config.toSwaggerConfig())
+	if err != nil {
+		return fmt.Errorf("rendering index.html: %w", err)
+	}
+	if err = os.WriteFile(filepath.Join(outDir, "index.html"), page, 0o644); err != nil {
+		return err
+	}
+
+	var source []byte
+	if config.SpecProvider != nil {
+		source, err = config.SpecProvider(frame.NewContext(0))
+	} else {
+		source, err = readDoc(config, h.spec)
+	}
+	if err != nil {
+		return fmt.Errorf("reading doc.json: %w", err)
+	}
+	doc, err := processDoc(config, source)
+	if err != nil {
+		return fmt.Errorf("processing doc.json: %w", err)
+	}
+	if err = os.WriteFile(filepath.Join(outDir, "doc.json"), doc, 0o644); err != nil {
+		return err
+	}
+
+	return writeAssets(context.Background(), config, "/", outDir)
+}
+
+// writeAssets copies the assets under dir in the file system to outDir, skipping the stock
+// index.html, which GenerateStaticSite renders itself.
+func writeAssets(c context.Context, config *Config, dir, outDir string) error {
+	f, err := config.Handler.FileSystem.OpenFile(c, dir, os.O_RDONLY, 0)
+	if err != nil {
+		return err
+	}
+	entries, err := f.Readdir(-1)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := path.Join(dir, entry.Name())
+		if entry.IsDir() {
+			if err = writeAssets(c, config, name, outDir); err != nil {
+				return err
+			}
+			continue
+		}
+		asset := name[1:]
+		if asset == "index.html" || !assetAllowed(config, strings.TrimSuffix(asset, ".gz")) {
+			continue
+		}
+		data, err := readAsset(c, config, name)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", asset, err)
+		}
+		out := filepath.Join(outDir, filepath.FromSlash(asset))
+		if err = os.MkdirAll(filepath.Dir(out), 0o755); err != nil {
+			return err
+		}
+		if err = os.WriteFile(out, data, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package swagger
+
+import (
+	"encoding/hex"
+	"strings"
+	"sync/atomic"
+)
+
+// cacheCounters counts the lookups of a cache.
+type cacheCounters struct {
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+func (cc *cacheCounters) record(hit bool) {
+	if hit {
+		cc.hits.Add(1)
+	} else {
+		cc.misses.Add(1)
+	}
+}
+
+// cacheStats is the part of stats.json describing one cache.
+type cacheStats struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+}
+
+func (cc *cacheCounters) stats() cacheStats {
+	return cacheStats{Hits: cc.hits.Load(), Misses: cc.misses.Load()}
+}
+
+// compressedStats describes a body held by the compression cache.
+type compressedStats struct {
+	Size       int     `json:"size"`
+	Compressed int     `json:"compressed"`
+	Ratio      float64 `json:"ratio"`
+}
+
+// stats is the document served as stats.json when Config.EnableStats is set.
+type stats struct {
+	Assets struct {
+		cacheStats
+		Files map[string]int `json:"files"`
+	} `json:"assets"`
+	Compression struct {
+		cacheStats
+		Files map[string]compressedStats `json:"files"`
+	} `json:"compression"`
+	Spec cacheStats `json:"spec"`
+}
+
+func (h *handler) stats() stats {
+	var s stats
+	s.Assets.cacheStats = h.assets.counters.stats()
+	s.Assets.Files = map[string]int{}
+	h.assets.mu.RLock()
+	for path, data := range h.assets.files {
+		s.Assets.Files[strings.TrimPrefix(path, "/")] = len(data)
+	}
+	h.assets.mu.RUnlock()
+
+	s.Compression.cacheStats = h.gzipped.counters.stats()
+	s.Compression.Files = map[string]compressedStats{}
+	h.gzipped.mu.RLock()
+	for key, gz := range h.gzipped.files {
+		size := h.gzipped.sizes[key]
+		entry := compressedStats{Size: size, Compressed: len(gz)}
+		if size > 0 {
+			entry.Ratio = float64(len(gz)) / float64(size)
+		}
+		s.Compression.Files[statsName(key)] = entry
+	}
+	h.gzipped.mu.RUnlock()
+
+	s.Spec = h.docs.counters.stats()
+	return s
+}
+
+// statsName shortens a contentKey to its name and the start of its hash.
+func statsName(key string) string {
+	name, sum, ok := strings.Cut(key, "@")
+	if !ok {
+		return strings.TrimPrefix(key, "/")
+	}
+	if len(sum) > 4 {
+		sum = sum[:4]
+	}
+	return name + "@" + hex.EncodeToString([]byte(sum))
+}
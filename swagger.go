@@ -1,22 +1,38 @@
 package swagger
 
 import (
-	"bytes"
-	"context"
+	"compress/gzip"
+	"errors"
+	"fmt"
 	"html/template"
 	"net/http"
-	"os"
-	"path/filepath"
 	"regexp"
-	"sync"
+	"strings"
+	"time"
 
 	"github.com/oarkflow/frame"
-	"github.com/oarkflow/frame/pkg/protocol/consts"
+	"github.com/oarkflow/frame/middlewares/server/basic_auth"
+	"github.com/oarkflow/frame/pkg/route"
 	swaggerFiles "github.com/swaggo/files"
 	"github.com/swaggo/swag"
 	"golang.org/x/net/webdav"
 )
 
+// SwaggerUIVersion is the version of Swagger UI shipped by the swaggo/files assets.
+const SwaggerUIVersion = "4.15.5"
+
+// Swagger UI layouts for Config.Layout.
+const (
+	StandaloneLayout = "StandaloneLayout"
+	BaseLayout       = "BaseLayout"
+)
+
+// Line endings for Config.SpecLineEndings.
+const (
+	LineEndingsLF   = "lf"
+	LineEndingsCRLF = "crlf"
+)
+
 type swaggerConfig struct {
 	URL                      string
 	DocExpansion             string
@@ -26,23 +42,232 @@ type swaggerConfig struct {
 	DeepLinking              bool
 	PersistAuthorization     bool
 	Oauth2DefaultClientID    string
+	WithCredentials          bool
+	ScrollToAnchor           bool
+	MaxResponseRenderBytes   int
+	Layout                   string
+	ValidatorURL             string
+	Oauth2AdditionalParams   map[string]string
+	AssetPrefix              string
+	RequestSnippetsEnabled   bool
+	DefaultSnippetLang       string
+	GoogleAnalyticsID        string
+	AnalyticsScript          template.JS
+	TokenExpiry              int64
+	MaxTryItOutBodyBytes     int
+	TryItOutDisabled         bool
+	RenderCodeSamples        bool
+	AllowSpecURLEditing      bool
 }
 
 // Config stores hertzSwagger configuration variables.
 type Config struct {
 	// The url pointing to API definition (normally swagger.json or swagger.yaml). Default is `doc.json`.
-	URL                      string
-	DocExpansion             string
-	InstanceName             string
+	URL          string
+	DocExpansion string
+	InstanceName string
+	// InstanceNames, when set, replaces InstanceName with a fallback chain: the spec is read from
+	// the first of them registered with swag, so a renamed instance doesn't break the docs.
+	InstanceNames            []string
 	Title                    string
 	DefaultModelsExpandDepth int
 	DeepLinking              bool
-	PersistAuthorization     bool
-	Oauth2DefaultClientID    string
-	Handler                  *webdav.Handler
+	// OperationsExpanded and SchemasCollapsed are presets for the common combination of operations
+	// shown expanded and the Schemas section collapsed. OperationsExpanded sets DocExpansion to `full`
+	// and SchemasCollapsed sets DefaultModelsExpandDepth to 0, which is otherwise taken as unset.
+	// The Schemas section starts expanded only when DefaultModelsExpandDepth is positive and
+	// DocExpansion isn't `none`; a depth of -1 hides it.
+	OperationsExpanded bool
+	SchemasCollapsed   bool
+	// PersistAuthorization keeps the authorization entered in the UI across page reloads. Unlike
+	// DefaultModelsExpandDepth, its zero value is also its default, so unset and false need no telling apart.
+	PersistAuthorization  bool
+	Oauth2DefaultClientID string
+	// Oauth2AdditionalParams are added to the OAuth2 authorize request, e.g. `audience` for Auth0 or `resource` for ADFS.
+	Oauth2AdditionalParams map[string]string
+	// SpecFile is the path of a spec document on disk served as doc.json instead of the swag instance.
+	SpecFile string
+	// MaxCachedSpecs bounds how many specs are cached, evicting the least recently used beyond it.
+	// Each variant, SpecProvider document and request dependent view, such as one per scheme for
+	// AutoSchemes or per set of allowed tags for TagAccess, counts as one. Zero means no bound.
+	MaxCachedSpecs int
+	// SpecProvider, when set, is the source of doc.json for each request, replacing the swag instance,
+	// SpecFile and SpecVariants. Its documents still go through the configured transformations and
+	// compression; only the last one is cached, so a provider answering per request doesn't grow the cache.
+	SpecProvider func(ctx *frame.Context) ([]byte, error)
+	// WatchSpecFile reloads the cached SpecFile whenever it changes on disk. On Linux a file watcher
+	// (inotify) on its directory reports changes; elsewhere, or without inotify, the modification time
+	// is polled every second. The watcher stops on Handler.Close and Handler.UpdateConfig, or once the
+	// handler is discarded.
+	WatchSpecFile bool
+	// BasicAuth protects the UI, doc.json and assets with HTTP basic auth, keyed by username.
+	BasicAuth basic_auth.Accounts
+	// DevMode disables the spec, asset and compression caches, reports error details in responses
+	// and enables the `reload` endpoint, which drops anything cached so far.
+	DevMode bool
+	// EnableStats serves stats.json with the hit and miss counts of the asset, compression and spec
+	// caches, the sizes of cached assets and the ratios of compressed bodies.
+	EnableStats bool
+	// LowMemoryMode turns off the asset, spec and compression caches and streams assets straight from
+	// the file system in small chunks, for constrained targets that can't afford the memory.
+	// Precompressed `.gz` variants are still served.
+	LowMemoryMode bool
+	// Disabled turns the handler off, answering every request with DisabledStatus and DisabledMessage.
+	Disabled bool
+	// DisabledStatus is the status returned while Disabled. Default is `404`.
+	DisabledStatus int
+	// DisabledMessage is the body returned while Disabled. Default is the text of DisabledStatus.
+	DisabledMessage string
+	// PostLoad transforms the spec once it is read, before the transformations configured here and
+	// before the result is cached. It runs again only when the source document changes.
+	PostLoad func(spec []byte) ([]byte, error)
+	// PathPrefixFilter, when set, trims the served spec to the paths starting with it, such as `/admin/`.
+	PathPrefixFilter string
+	// DefaultParameterSerialization is the Swagger 2.0 collection format, `csv`, `multi`, `ssv`, `tsv` or `pipes`,
+	// given to array query parameters that don't define one, and mapped to the matching OpenAPI 3 style and
+	// explode. It makes Try it out encode arrays the way the API expects. OpenAPI 3 has no style for `tsv`,
+	// so it only applies to Swagger 2.0 specs.
+	DefaultParameterSerialization string
+	// DereferenceRefs inlines internal `$ref`s into the served doc.json for tools that can't follow them.
+	// Circular references are left as `$ref`s, since expanding them would never end, and so are `$ref`s
+	// that point nowhere in the document. External references, to other files or URLs, are not followed.
+	DereferenceRefs bool
+	// IndexTemplate replaces the built-in index page. It is an html/template executed with the same
+	// values as the built-in one; see swaggerIndexTpl.
+	IndexTemplate string
+	// Renderers offers the spec in several renderers from one mount: RendererSwaggerUI at index.html,
+	// and RendererReDoc and RendererRapiDoc under `redoc` and `rapidoc`, all reading the same doc.json.
+	// When set, the bare mount serves a page linking to each, in order, instead of redirecting. ReDoc and
+	// RapiDoc are loaded from their CDNs at pinned releases.
+	Renderers []string
+	// ErrorTemplate, when set, renders the body of error responses, such as `404` or `500`, instead of
+	// plain text. It is an html/template executed with the Status code, its StatusText, the Message
+	// otherwise sent and the page Title.
+	ErrorTemplate string
+	// TemplateFuncs are made available to IndexTemplate, which they require.
+	TemplateFuncs template.FuncMap
+	// ValidatorURL is the validator used for the badge at the bottom of the UI. The badge is hidden when it is empty,
+	// which is the default.
+	ValidatorURL string
+	// DisableValidator hides the validator badge even when ValidatorURL is set.
+	DisableValidator bool
+	// RequestSnippetsEnabled shows cURL snippets for Try it out requests.
+	RequestSnippetsEnabled bool
+	// DefaultSnippetLang is the snippet selected when the snippets open, one of `curl_bash`, `curl_powershell`
+	// or `curl_cmd`. Default is `curl_bash`.
+	DefaultSnippetLang string
+	// Layout is the Swagger UI layout, StandaloneLayout or BaseLayout. The standalone preset is only loaded
+	// for StandaloneLayout. Default is StandaloneLayout.
+	Layout string
+	// GoogleAnalyticsID adds the Google Analytics tag for a measurement ID such as `G-XXXXXXXXXX`. IDs of
+	// any other shape are ignored.
+	GoogleAnalyticsID string
+	// AnalyticsScript is JavaScript run in a script tag of the page head. It is not escaped and must come
+	// from a trusted source; a script containing `</script` is ignored, as it could inject markup.
+	AnalyticsScript template.JS
+	// AllowQueryOverrides lets the page URL override some options for debugging, e.g. `?expandDepth=3`
+	// for DefaultModelsExpandDepth.
+	AllowQueryOverrides bool
+	// ContentSecurityPolicy sends a Content-Security-Policy with the docs page that only allows what
+	// the page itself needs.
+	ContentSecurityPolicy bool
+	// APIOrigins are added to the `connect-src` of the Content-Security-Policy so Try it out can call
+	// an API, or an OAuth2 token endpoint, on another origin, e.g. `https://api.example.com`.
+	APIOrigins []string
+	// ScrollToAnchor smoothly scrolls to the deep-linked operation once the UI has loaded.
+	ScrollToAnchor bool
+	// MaxResponseRenderBytes truncates Try it out response bodies longer than this before they are rendered,
+	// keeping the UI responsive on large payloads. Zero renders bodies in full.
+	MaxResponseRenderBytes int
+	// AllowSpecURLEditing shows the spec URL input in the top bar of StandaloneLayout, which is hidden
+	// by default, so users can explore a spec from another URL. Use it for debugging only: the page then
+	// renders whatever spec is pasted, and Try it out sends requests, with any authorization entered,
+	// to the servers that spec names.
+	AllowSpecURLEditing bool
+	// RenderCodeSamples shows the `x-codeSamples` of an operation, which Swagger UI ignores, below it once expanded.
+	RenderCodeSamples bool
+	// MaxTryItOutBodyBytes aborts Try it out requests whose body is larger than this, with a warning,
+	// before the browser freezes sending it. Zero means no limit.
+	MaxTryItOutBodyBytes int
+	// AllowedAssets, when not empty, is the only set of assets that can be served, such as `swagger-ui.css`.
+	// Any other asset is answered with `404`, whatever the file system holds.
+	AllowedAssets []string
+	// AssetMaxAge is how long browsers may cache assets. Fingerprinted assets, whose name
+	// carries a content hash, are always cached for a year. Default is one hour.
+	AssetMaxAge time.Duration
+	// SpecMaxAge is how long browsers and CDNs may cache doc.json before revalidating it against
+	// its strong ETag. By default it must be revalidated on every use (`no-cache`). With BasicAuth,
+	// AutoSchemes, TagAccess, SpecVariant or SpecProvider the spec is only cached privately, by the browser.
+	SpecMaxAge time.Duration
+	// JSONContentType is the Content-Type of JSON responses. Default is `application/json; charset=utf-8`;
+	// set it to `application/json` for gateways that reject the charset parameter.
+	JSONContentType string
+	// AssetPrefix is used verbatim as `Handler.Prefix` and, with a trailing slash, as the prefix of asset links
+	// in the page, bypassing the prefix detected from the request URI. Use it when a proxy rewrites paths.
+	// Default is `./`.
+	AssetPrefix string
+	// PublicBasePath is the path clients reach the mount at, such as `/api/docs/` when a proxy or a Unix socket
+	// front rewrites paths. When set it replaces the mount path the request arrived on for asset links and a
+	// relative URL, unless AssetPrefix is set, and for the RootRedirect, so the UI never depends on the host
+	// or path seen by the app. It is required behind a proxy that strips a path prefix: links are built from the
+	// path the app sees, which then lacks the prefix clients need.
+	PublicBasePath string
+	// StableSpecOrdering serves the spec with its object keys sorted, so downloaded specs diff cleanly.
+	StableSpecOrdering bool
+	// StripBOM removes a UTF-8 byte order mark from the start of the spec before it is processed and served.
+	StripBOM bool
+	// SpecLineEndings normalizes the line endings of the served spec to LineEndingsLF or LineEndingsCRLF.
+	// By default they are left as they are.
+	SpecLineEndings string
+	// MaintenanceMode keeps serving the docs but disables Try it out and shows MaintenanceMessage
+	// at the top of the spec description.
+	MaintenanceMode bool
+	// MaintenanceMessage is shown while in MaintenanceMode. Default is `This API is under maintenance.`
+	MaintenanceMessage string
+	// AuthorizeForTryItOut is asked, when the page is rendered, whether the caller may use Try it out.
+	// Everyone who passes BasicAuth can view the docs, but callers it rejects get a page without the
+	// Try it out buttons. This only shapes the UI: the API itself must still authorize its requests.
+	AuthorizeForTryItOut func(ctx *frame.Context) bool
+	// RootRedirect is where requests for the bare mount path are redirected, relative to the mount
+	// unless it is absolute. Default is `index.html`.
+	RootRedirect string
+	// RootSpecPath is a path outside the UI mount, such as `/openapi.json`, served by RegisterRootSpec.
+	RootSpecPath string
+	// AutoSchemes sets the `schemes` of a served Swagger 2.0 spec to the protocol of the request,
+	// so one build serves the right scheme over both http and https.
+	AutoSchemes bool
+	// SpecVariants maps variant names to the swag instance serving the spec of that variant,
+	// e.g. to try out different descriptions on a subset of users.
+	SpecVariants map[string]string
+	// SpecVariant picks the variant in SpecVariants served to the request. The spec of InstanceName,
+	// or the SpecFile, is served when it returns a name not in SpecVariants, including "".
+	SpecVariant func(ctx *frame.Context) string
+	// TagAccess is asked, per request and tag, whether the caller may see the operations of that tag.
+	// Operations none of whose tags are allowed are left out of the served spec; untagged operations
+	// are checked as the `default` tag.
+	TagAccess func(ctx *frame.Context, tag string) bool
+	// OAuthEndpoints rewrites the URLs of OAuth2 security schemes in the served spec, keyed by scheme name,
+	// so one spec can point at the auth server of each environment.
+	OAuthEndpoints map[string]OAuthEndpoint
+	// Compress gzip encodes responses for clients that accept it. Compressed assets and specs are
+	// cached, and responses carry `Vary: Accept-Encoding` so caches keep the encodings apart.
+	Compress bool
+	// CompressionLevel is the gzip level used by Compress, from gzip.HuffmanOnly to gzip.BestCompression.
+	// Zero, which would mean gzip.NoCompression, selects the default, gzip.DefaultCompression.
+	CompressionLevel int
+	// TokenExpiry is when the token pre-filled for Try it out expires. When set, the page shows a countdown
+	// banner that turns into a warning as expiry nears, and an error once it has passed.
+	TokenExpiry time.Time
+	// RateLimit caps requests per client IP, answering `429` with `Retry-After` once exceeded.
+	RateLimit *RateLimit
+	Handler   *webdav.Handler
 }
 
 func (config Config) toSwaggerConfig() swaggerConfig {
+	params := config.Oauth2AdditionalParams
+	if params == nil {
+		params = map[string]string{}
+	}
 	return swaggerConfig{
 		URL:                      config.URL,
 		DeepLinking:              config.DeepLinking,
@@ -51,10 +276,96 @@ func (config Config) toSwaggerConfig() swaggerConfig {
 		Oauth2RedirectURL: "`${window.location.protocol}//${window.location.host}$" +
 			"{window.location.pathname.split('/').slice(0, window.location.pathname.split('/').length - 1).join('/')}" +
 			"/oauth2-redirect.html`",
-		Title:                 config.Title,
-		PersistAuthorization:  config.PersistAuthorization,
-		Oauth2DefaultClientID: config.Oauth2DefaultClientID,
+		Title:                  config.Title,
+		PersistAuthorization:   config.PersistAuthorization,
+		Oauth2DefaultClientID:  config.Oauth2DefaultClientID,
+		WithCredentials:        len(config.BasicAuth) > 0,
+		ScrollToAnchor:         config.ScrollToAnchor,
+		MaxResponseRenderBytes: config.MaxResponseRenderBytes,
+		Layout:                 config.Layout,
+		ValidatorURL:           config.validatorURL(),
+		Oauth2AdditionalParams: params,
+		AssetPrefix:            config.assetPrefix(),
+		RequestSnippetsEnabled: config.RequestSnippetsEnabled,
+		DefaultSnippetLang:     config.defaultSnippetLang(),
+		GoogleAnalyticsID:      config.googleAnalyticsID(),
+		AnalyticsScript:        config.analyticsScript(),
+		TryItOutDisabled:       config.MaintenanceMode,
+		TokenExpiry:            config.tokenExpiry(),
+		MaxTryItOutBodyBytes:   config.MaxTryItOutBodyBytes,
+		RenderCodeSamples:      config.RenderCodeSamples,
+		AllowSpecURLEditing:    config.AllowSpecURLEditing,
+	}
+}
+
+// tokenExpiry returns TokenExpiry in milliseconds since the epoch, as JavaScript dates count, or 0 when unset.
+func (config Config) tokenExpiry() int64 {
+	if config.TokenExpiry.IsZero() {
+		return 0
+	}
+	return config.TokenExpiry.UnixMilli()
+}
+
+// assetPrefix returns the prefix of asset links in the page.
+func (config Config) assetPrefix() string {
+	prefix := config.AssetPrefix
+	if prefix == "" {
+		prefix = config.PublicBasePath
+	}
+	if prefix == "" {
+		return "./"
+	}
+	if !strings.HasSuffix(prefix, "/") {
+		return prefix + "/"
+	}
+	return prefix
+}
+
+// snippetGenerators are the request snippet generators of Swagger UI, in their default order.
+var snippetGenerators = []string{"curl_bash", "curl_powershell", "curl_cmd"}
+
+// validSnippetLang reports whether lang names one of snippetGenerators.
+func validSnippetLang(lang string) bool {
+	for _, generator := range snippetGenerators {
+		if lang == generator {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultSnippetLang returns DefaultSnippetLang, or "" when it names no generator.
+func (config Config) defaultSnippetLang() string {
+	if !validSnippetLang(config.DefaultSnippetLang) {
+		return ""
+	}
+	return config.DefaultSnippetLang
+}
+
+var googleAnalyticsID = regexp.MustCompile(`^(?:G|UA|GT|AW)-[A-Z0-9-]+$`)
+
+// googleAnalyticsID returns GoogleAnalyticsID if it is well formed, "" otherwise.
+func (config Config) googleAnalyticsID() string {
+	if !googleAnalyticsID.MatchString(config.GoogleAnalyticsID) {
+		return ""
+	}
+	return config.GoogleAnalyticsID
+}
+
+// analyticsScript returns AnalyticsScript unless it could close its script tag.
+func (config Config) analyticsScript() template.JS {
+	if strings.Contains(strings.ToLower(string(config.AnalyticsScript)), "</script") {
+		return ""
 	}
+	return config.AnalyticsScript
+}
+
+// validatorURL returns the validator to render, or "" when it is disabled.
+func (config Config) validatorURL() string {
+	if config.DisableValidator {
+		return ""
+	}
+	return config.ValidatorURL
 }
 
 func defaultConfig() *Config {
@@ -65,13 +376,165 @@ func defaultConfig() *Config {
 		Title:                    "Swagger UI",
 		DefaultModelsExpandDepth: 1,
 		DeepLinking:              true,
+		Layout:                   StandaloneLayout,
 	}
 }
 
-// New wraps `http.Handler` into `frame.HandlerFunc`.
+// New wraps `http.Handler` into `frame.HandlerFunc`. Use NewHandler to be able to update the config at runtime.
 func New(cfg ...*Config) frame.HandlerFunc {
+	return NewHandler(cfg...).Serve
+}
+
+// NewWithError is like New but returns an error, instead of logging it and ignoring the
+// offending options, when the config is invalid.
+func NewWithError(cfg ...*Config) (frame.HandlerFunc, error) {
+	config := prepareConfig(cfg...)
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	return New(config), nil
+}
+
+// MustNew is like NewWithError but panics if the config is invalid, surfacing
+// misconfiguration at startup rather than on the first docs request. A nil cfg uses the defaults.
+func MustNew(cfg *Config) frame.HandlerFunc {
+	h, err := NewWithError(cfg)
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
+
+// NewSpecHandler returns a handler serving only the spec document, the same one as doc.json.
+func NewSpecHandler(cfg ...*Config) frame.HandlerFunc {
+	return newHandler("swagger_index.html", swaggerIndexTpl, cfg...).serveSpec
+}
+
+// RegisterRootSpec registers `config.RootSpecPath` on r, serving the spec for tools that expect it at a
+// well-known path such as `/openapi.json`. Nothing is registered when RootSpecPath is empty, or
+// config is nil, as the defaults leave it empty.
+func RegisterRootSpec(r route.IRoutes, config *Config) {
+	if config == nil || config.RootSpecPath == "" {
+		return
+	}
+	r.GET(config.RootSpecPath, NewSpecHandler(config))
+}
+
+var errTemplateFuncs = errors.New("swagger: TemplateFuncs requires IndexTemplate")
+
+// validate reports the options of a prepared config that can't be used.
+func (config *Config) validate() error {
+	var errs []error
+	if !validCompressionLevel(config.CompressionLevel) {
+		errs = append(errs, fmt.Errorf("swagger: invalid CompressionLevel %d", config.CompressionLevel))
+	}
+	if config.GoogleAnalyticsID != "" && config.googleAnalyticsID() == "" {
+		errs = append(errs, fmt.Errorf("swagger: malformed GoogleAnalyticsID %q", config.GoogleAnalyticsID))
+	}
+	if config.AnalyticsScript != "" && config.analyticsScript() == "" {
+		errs = append(errs, errors.New("swagger: AnalyticsScript must not contain </script"))
+	}
+	if config.RateLimit != nil && (config.RateLimit.Requests <= 0 || config.RateLimit.Interval <= 0) {
+		errs = append(errs, errors.New("swagger: RateLimit needs positive Requests and Interval"))
+	}
+	if config.Layout != StandaloneLayout && config.Layout != BaseLayout {
+		errs = append(errs, fmt.Errorf("swagger: unknown Layout %q", config.Layout))
+	}
+	if config.SpecLineEndings != "" && config.SpecLineEndings != LineEndingsLF && config.SpecLineEndings != LineEndingsCRLF {
+		errs = append(errs, fmt.Errorf("swagger: unknown SpecLineEndings %q", config.SpecLineEndings))
+	}
+	if config.DefaultParameterSerialization != "" && !validCollectionFormat(config.DefaultParameterSerialization) {
+		errs = append(errs, fmt.Errorf("swagger: unknown DefaultParameterSerialization %q", config.DefaultParameterSerialization))
+	}
+	if config.DefaultSnippetLang != "" && !validSnippetLang(config.DefaultSnippetLang) {
+		errs = append(errs, fmt.Errorf("swagger: unknown DefaultSnippetLang %q", config.DefaultSnippetLang))
+	}
+	if config.MaxCachedSpecs < 0 {
+		errs = append(errs, fmt.Errorf("swagger: invalid MaxCachedSpecs %d", config.MaxCachedSpecs))
+	}
+	if config.DisabledStatus < 100 || config.DisabledStatus > 599 {
+		errs = append(errs, fmt.Errorf("swagger: invalid DisabledStatus %d", config.DisabledStatus))
+	}
+	if len(config.TemplateFuncs) > 0 && config.IndexTemplate == "" {
+		errs = append(errs, errTemplateFuncs)
+	}
+	if config.IndexTemplate != "" {
+		if _, err := template.New("index").Funcs(config.TemplateFuncs).Parse(config.IndexTemplate); err != nil {
+			errs = append(errs, fmt.Errorf("swagger: IndexTemplate: %w", err))
+		}
+	}
+	for _, renderer := range config.Renderers {
+		if _, ok := rendererTitles[renderer]; !ok {
+			errs = append(errs, fmt.Errorf("swagger: unknown renderer %q", renderer))
+		}
+	}
+	if config.ErrorTemplate != "" {
+		if _, err := template.New("error").Parse(config.ErrorTemplate); err != nil {
+			errs = append(errs, fmt.Errorf("swagger: ErrorTemplate: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// fallBackToDefaults replaces the invalid values validate reports for a prepared config with
+// their defaults, so a config the handler logs as invalid still serves sensibly.
+func (config *Config) fallBackToDefaults() {
+	if !validCompressionLevel(config.CompressionLevel) {
+		config.CompressionLevel = gzip.DefaultCompression
+	}
+	if config.DisabledStatus < 100 || config.DisabledStatus > 599 {
+		config.DisabledStatus = http.StatusNotFound
+		if config.DisabledMessage == "" {
+			config.DisabledMessage = http.StatusText(config.DisabledStatus)
+		}
+	}
+	if config.Layout != StandaloneLayout && config.Layout != BaseLayout {
+		config.Layout = StandaloneLayout
+	}
+	if config.SpecLineEndings != LineEndingsLF && config.SpecLineEndings != LineEndingsCRLF {
+		config.SpecLineEndings = ""
+	}
+}
+
+// EffectiveConfig returns the configuration New and the other constructors actually use for cfg,
+// with every default filled in and the values they ignore cleared or replaced, such as a malformed
+// GoogleAnalyticsID, an invalid RateLimit or DisabledStatus, or TemplateFuncs without IndexTemplate,
+// to help find out why an option didn't apply. A nil cfg gives the configuration used when none is
+// passed. cfg itself is left untouched.
+func EffectiveConfig(cfg *Config) *Config {
+	var config Config
+	if cfg != nil {
+		config = *cfg
+	} else {
+		config = *defaultConfig()
+	}
+	effective := prepareConfig(&config)
+	effective.fallBackToDefaults()
+	effective.GoogleAnalyticsID = effective.googleAnalyticsID()
+	effective.AnalyticsScript = effective.analyticsScript()
+	if rl := effective.RateLimit; rl != nil && (rl.Requests <= 0 || rl.Interval <= 0) {
+		effective.RateLimit = nil
+	}
+	if effective.IndexTemplate == "" {
+		effective.TemplateFuncs = nil
+	}
+	if !validCollectionFormat(effective.DefaultParameterSerialization) {
+		effective.DefaultParameterSerialization = ""
+	}
+	if !validSnippetLang(effective.DefaultSnippetLang) {
+		effective.DefaultSnippetLang = ""
+	}
+	if effective.ErrorTemplate != "" {
+		if _, err := template.New("error").Parse(effective.ErrorTemplate); err != nil {
+			effective.ErrorTemplate = ""
+		}
+	}
+	return effective
+}
+
+func prepareConfig(cfg ...*Config) *Config {
 	var config *Config
-	if len(cfg) > 0 {
+	if len(cfg) > 0 && cfg[0] != nil {
 		config = cfg[0]
 	} else {
 		config = defaultConfig()
@@ -88,93 +551,44 @@ func New(cfg ...*Config) frame.HandlerFunc {
 	if config.Title == "" {
 		config.Title = "Swagger UI"
 	}
-	if config.DefaultModelsExpandDepth == 0 {
+	if config.OperationsExpanded {
+		config.DocExpansion = "full"
+	}
+	if config.SchemasCollapsed {
+		// Swagger UI only opens the Schemas section when the depth is positive
+		// and DocExpansion isn't `none`; zero lists the models collapsed.
+		config.DefaultModelsExpandDepth = 0
+	} else if config.DefaultModelsExpandDepth == 0 {
 		config.DefaultModelsExpandDepth = 1
 	}
+	if config.MaintenanceMessage == "" {
+		config.MaintenanceMessage = "This API is under maintenance."
+	}
+	if config.RootRedirect == "" {
+		config.RootRedirect = "index.html"
+	}
+	if config.Layout == "" {
+		config.Layout = StandaloneLayout
+	}
+	if config.DisabledStatus == 0 {
+		config.DisabledStatus = http.StatusNotFound
+	}
+	if config.DisabledMessage == "" {
+		config.DisabledMessage = http.StatusText(config.DisabledStatus)
+	}
+	if config.JSONContentType == "" {
+		config.JSONContentType = "application/json; charset=utf-8"
+	}
+	if config.CompressionLevel == 0 {
+		config.CompressionLevel = gzip.DefaultCompression
+	}
+	if config.AssetMaxAge == 0 {
+		config.AssetMaxAge = time.Hour
+	}
 	if config.Handler == nil {
 		config.Handler = swaggerFiles.Handler
 	}
-
-	var once sync.Once
-
-	// create a template with name
-	index, _ := template.New("swagger_index.html").Parse(swaggerIndexTpl)
-
-	matcher := regexp.MustCompile(`(.*)(index\.html|doc\.json|favicon-16x16\.png|favicon-32x32\.png|/oauth2-redirect\.html|swagger-ui\.css|swagger-ui\.css\.map|swagger-ui\.js|swagger-ui\.js\.map|swagger-ui-bundle\.js|swagger-ui-bundle\.js\.map|swagger-ui-standalone-preset\.js|swagger-ui-standalone-preset\.js\.map)[?|.]*`)
-
-	return func(c context.Context, ctx *frame.Context) {
-		if string(ctx.Request.Method()) != consts.MethodGet {
-			ctx.AbortWithStatus(http.StatusMethodNotAllowed)
-			return
-		}
-
-		matches := matcher.FindStringSubmatch(ctx.Request.URI().String())
-		if len(matches) != 3 && ctx.Param("any") != "" {
-			ctx.String(http.StatusNotFound, http.StatusText(http.StatusNotFound))
-
-			return
-		}
-		path := ""
-		if ctx.Param("any") != "" {
-			path = matches[2]
-		} else {
-			path = "index.html"
-		}
-		prefix := ""
-		if len(matches) != 3 {
-			prefix = ctx.Request.URI().String()
-		} else {
-			prefix = matches[1]
-		}
-
-		once.Do(func() {
-			config.Handler.Prefix = prefix
-		})
-
-		switch filepath.Ext(path) {
-		case ".html":
-			ctx.Header("Content-Type", "text/html; charset=utf-8")
-		case ".css":
-			ctx.Header("Content-Type", "text/css; charset=utf-8")
-		case ".js":
-			ctx.Header("Content-Type", "application/javascript")
-		case ".png":
-			ctx.Header("Content-Type", "image/png")
-		case ".json":
-			ctx.Header("Content-Type", "application/json; charset=utf-8")
-		}
-
-		switch path {
-		case "index.html":
-			_ = index.Execute(ctx, config.toSwaggerConfig())
-		case "doc.json":
-			doc, err := swag.ReadDoc(config.InstanceName)
-			if err != nil {
-				ctx.AbortWithStatus(http.StatusInternalServerError)
-				return
-			}
-			if _, err = ctx.Write([]byte(doc)); err != nil {
-				ctx.AbortWithStatus(http.StatusInternalServerError)
-				return
-			}
-
-		default:
-			f, err := config.Handler.FileSystem.OpenFile(c, path, os.O_RDONLY, 0)
-			if err != nil {
-				ctx.AbortWithStatus(http.StatusInternalServerError)
-				return
-			}
-			buf := new(bytes.Buffer)
-			if _, err = buf.ReadFrom(f); err != nil {
-				ctx.AbortWithStatus(http.StatusInternalServerError)
-				return
-			}
-			if _, err = ctx.Write(buf.Bytes()); err != nil {
-				ctx.AbortWithStatus(http.StatusInternalServerError)
-				return
-			}
-		}
-	}
+	return config
 }
 
 const swaggerIndexTpl = `<!-- HTML for static distribution bundle build -->
@@ -184,9 +598,21 @@ const swaggerIndexTpl = `<!-- HTML for static distribution bundle build -->
   <meta charset="UTF-8">
   <title>{{.Title}}</title>
   <link href="https://fonts.googleapis.com/css?family=Open+Sans:400,700|Source+Code+Pro:300,600|Titillium+Web:400,600,700" rel="stylesheet">
-  <link rel="stylesheet" type="text/css" href="./swagger-ui.css" >
-  <link rel="icon" type="image/png" href="./favicon-32x32.png" sizes="32x32" />
-  <link rel="icon" type="image/png" href="./favicon-16x16.png" sizes="16x16" />
+  <link rel="stylesheet" type="text/css" href="{{.AssetPrefix}}swagger-ui.css" >
+  <link rel="icon" type="image/png" href="{{.AssetPrefix}}favicon-32x32.png" sizes="32x32" />
+  <link rel="icon" type="image/png" href="{{.AssetPrefix}}favicon-16x16.png" sizes="16x16" />
+{{- if .GoogleAnalyticsID}}
+  <script async src="https://www.googletagmanager.com/gtag/js?id={{.GoogleAnalyticsID}}"></script>
+  <script>
+    window.dataLayer = window.dataLayer || [];
+    function gtag(){dataLayer.push(arguments);}
+    gtag("js", new Date());
+    gtag("config", "{{.GoogleAnalyticsID}}");
+  </script>
+{{- end}}
+{{- if .AnalyticsScript}}
+  <script>{{.AnalyticsScript}}</script>
+{{- end}}
   <style>
     html
     {
@@ -205,6 +631,12 @@ const swaggerIndexTpl = `<!-- HTML for static distribution bundle build -->
       margin:0;
       background: #fafafa;
     }
+{{- if not .AllowSpecURLEditing}}
+
+    .swagger-ui .topbar .download-url-wrapper {
+      display: none;
+    }
+{{- end}}
   </style>
 </head>
 
@@ -246,34 +678,211 @@ const swaggerIndexTpl = `<!-- HTML for static distribution bundle build -->
 
 <div id="swagger-ui"></div>
 
-<script src="./swagger-ui-bundle.js"> </script>
-<script src="./swagger-ui-standalone-preset.js"> </script>
+<script src="{{.AssetPrefix}}swagger-ui-bundle.js"> </script>
+{{- if eq .Layout "StandaloneLayout"}}
+<script src="{{.AssetPrefix}}swagger-ui-standalone-preset.js"> </script>
+{{- end}}
 <script>
+{{- if .ScrollToAnchor}}
+// Deep links look like #/tag or #/tag/operationId, which Swagger UI renders
+// as the elements #operations-tag-tag and #operations-tag-operationId.
+function scrollToDeepLink() {
+  const parts = decodeURIComponent(window.location.hash).split("/").slice(1);
+  if (parts.length === 0 || !parts[0]) {
+    return;
+  }
+  const id = parts.length > 1 ? "operations-" + parts[0] + "-" + parts[1] : "operations-tag-" + parts[0];
+  window.requestAnimationFrame(function() {
+    const target = document.getElementById(id);
+    if (target) {
+      target.scrollIntoView({ behavior: "smooth", block: "start" });
+    }
+  });
+}
+{{- end}}
+{{- if .MaxResponseRenderBytes}}
+function truncateResponse(response) {
+  const limit = {{.MaxResponseRenderBytes}};
+  if (response.url === new URL("{{.URL}}", window.location.href).href) {
+    return response;
+  }
+  if (typeof response.text === "string" && response.text.length > limit) {
+    const notice = "\n\n[Response truncated to " + limit + " of " + response.text.length + " bytes]";
+    response.text = response.text.slice(0, limit) + notice;
+    response.data = response.text;
+    response.body = response.text;
+    response.obj = undefined;
+  }
+  return response;
+}
+{{- end}}
+{{- if .MaxTryItOutBodyBytes}}
+function limitRequestBody(request) {
+  const limit = {{.MaxTryItOutBodyBytes}};
+  const body = request.body;
+  if (body === undefined || body === null) {
+    return request;
+  }
+  let size = 0;
+  if (typeof body === "string" || body instanceof Blob) {
+    size = new Blob([body]).size;
+  } else if (body instanceof FormData) {
+    body.forEach(function(value) {
+      size += new Blob([value]).size;
+    });
+  }
+  if (size > limit) {
+    const message = "The request body is " + size + " bytes, more than the " + limit + " allowed; the request was not sent.";
+    window.alert(message);
+    throw new Error(message);
+  }
+  return request;
+}
+{{- end}}
+{{- if .RenderCodeSamples}}
+// CodeSamplesPlugin renders the x-codeSamples, or the older x-code-samples, of an
+// operation below it while it is expanded.
+function CodeSamplesPlugin(system) {
+  const h = system.React.createElement;
+  return {
+    wrapComponents: {
+      operation: function(Original) {
+        return function(props) {
+          const op = props.operation && props.operation.get("op");
+          const samples = op && (op.get("x-codeSamples") || op.get("x-code-samples"));
+          if (!props.isShown || !samples || !samples.size) {
+            return h(Original, props);
+          }
+          const HighlightCode = system.getComponent("highlightCode");
+          return h("div", null,
+            h(Original, props),
+            h("div", { className: "opblock-section code-samples", style: { padding: "0 20px 20px" } },
+              h("h4", null, "Code samples"),
+              samples.map(function(sample, i) {
+                return h("div", { key: i },
+                  h("h5", null, sample.get("label") || sample.get("lang")),
+                  h(HighlightCode, { value: sample.get("source") || "" }));
+              }).toArray()));
+        };
+      }
+    }
+  };
+}
+{{- end}}
+{{- if .DefaultSnippetLang}}
+// DefaultSnippetPlugin lists the DefaultSnippetLang generator first. The snippets
+// panel selects the first generator, whatever the order of requestSnippets.languages.
+function DefaultSnippetPlugin() {
+  const lang = "{{.DefaultSnippetLang}}";
+  return {
+    statePlugins: {
+      requestSnippets: {
+        wrapSelectors: {
+          getSnippetGenerators: function(ori) {
+            return function() {
+              return ori().sortBy(function(generator, key) {
+                return key === lang ? 0 : 1;
+              });
+            };
+          }
+        }
+      }
+    }
+  };
+}
+{{- end}}
+{{- if .TokenExpiry}}
+function showTokenExpiry() {
+  const expiry = {{.TokenExpiry}};
+  const banner = document.createElement("div");
+  banner.id = "token-expiry";
+  banner.style.cssText = "position:sticky;top:0;z-index:1000;padding:8px 20px;font:14px sans-serif;text-align:center;color:#fff";
+  document.body.insertBefore(banner, document.body.firstChild);
+  function update() {
+    const left = Math.floor((expiry - Date.now()) / 1000);
+    if (left <= 0) {
+      banner.style.background = "#f93e3e";
+      banner.textContent = "The pre-filled token has expired; Try it out requests will be rejected until you authorize again.";
+      return false;
+    }
+    const minutes = Math.floor(left / 60);
+    const seconds = ("0" + left % 60).slice(-2);
+    banner.style.background = left < 300 ? "#fca130" : "#49cc90";
+    banner.textContent = "The pre-filled token expires in " + minutes + ":" + seconds + ".";
+    return true;
+  }
+  if (update()) {
+    const timer = window.setInterval(function() {
+      if (!update()) {
+        window.clearInterval(timer);
+      }
+    }, 1000);
+  }
+}
+{{- end}}
 window.onload = function() {
   // Build a system
   const ui = SwaggerUIBundle({
     url: "{{.URL}}",
     dom_id: '#swagger-ui',
-    validatorUrl: null,
+    validatorUrl: {{if .ValidatorURL}}"{{.ValidatorURL}}"{{else}}null{{end}},
     oauth2RedirectUrl: {{.Oauth2RedirectURL}},
     persistAuthorization: {{.PersistAuthorization}},
+    withCredentials: {{.WithCredentials}},
+{{- if .TryItOutDisabled}}
+    supportedSubmitMethods: [],
+{{- end}}
+{{- if .RequestSnippetsEnabled}}
+    requestSnippetsEnabled: true,
+    requestSnippets: {
+      generators: {
+        curl_bash: { title: "cURL (bash)", syntax: "bash" },
+        curl_powershell: { title: "cURL (PowerShell)", syntax: "powershell" },
+        curl_cmd: { title: "cURL (CMD)", syntax: "bash" }
+      },
+      defaultExpanded: true
+    },
+{{- end}}
     presets: [
       SwaggerUIBundle.presets.apis,
+{{- if eq .Layout "StandaloneLayout"}}
       SwaggerUIStandalonePreset
+{{- end}}
     ],
     plugins: [
-      SwaggerUIBundle.plugins.DownloadUrl
+      SwaggerUIBundle.plugins.DownloadUrl{{if .RenderCodeSamples}},
+      CodeSamplesPlugin{{end}}{{if .DefaultSnippetLang}},
+      DefaultSnippetPlugin{{end}}
     ],
-	layout: "StandaloneLayout",
+{{- if .MaxTryItOutBodyBytes}}
+    requestInterceptor: limitRequestBody,
+{{- end}}
+    responseInterceptor: function(response) {
+{{- if .MaxResponseRenderBytes}}
+      response = truncateResponse(response)
+{{- end}}
+      return response
+    },
+    onComplete: function() {
+{{- if .ScrollToAnchor}}
+      scrollToDeepLink()
+{{- end}}
+{{- if .TokenExpiry}}
+      showTokenExpiry()
+{{- end}}
+    },
+	layout: "{{.Layout}}",
     docExpansion: "{{.DocExpansion}}",
 	deepLinking: {{.DeepLinking}},
 	defaultModelsExpandDepth: {{.DefaultModelsExpandDepth}}
   })
 
   const defaultClientId = "{{.Oauth2DefaultClientID}}";
-  if (defaultClientId) {
+  const additionalQueryStringParams = {{.Oauth2AdditionalParams}};
+  if (defaultClientId || Object.keys(additionalQueryStringParams).length > 0) {
     ui.initOAuth({
-      clientId: defaultClientId
+      clientId: defaultClientId,
+      additionalQueryStringParams: additionalQueryStringParams
     })
   }
 
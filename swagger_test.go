@@ -0,0 +1,804 @@
+package swagger
+
+import (
+	"compress/gzip"
+	"context"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oarkflow/frame"
+	"github.com/oarkflow/frame/pkg/common/config"
+	"github.com/oarkflow/frame/pkg/route"
+	"github.com/swaggo/swag"
+	"golang.org/x/net/webdav"
+)
+
+const testInstance = "swagger_test"
+
+const testDoc = `{"swagger": "2.0", "info": {"title": "test"}, "paths": {"/ping": {"get": {"tags": ["ping"]}}}}`
+
+type testSpec struct{}
+
+func (testSpec) ReadDoc() string { return testDoc }
+
+func init() {
+	swag.Register(testInstance, testSpec{})
+}
+
+// request serves a GET for target through handler, with header given as name, value pairs.
+func request(handler http.Handler, target string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// memAssets returns a file handler holding the named files.
+func memAssets(t *testing.T, names ...string) *webdav.Handler {
+	t.Helper()
+	fs := webdav.NewMemFS()
+	for _, name := range names {
+		f, err := fs.OpenFile(context.Background(), name, os.O_CREATE|os.O_RDWR, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = f.Write([]byte("/* " + name + " */")); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	}
+	return &webdav.Handler{FileSystem: fs}
+}
+
+func assertStatus(t *testing.T, rec *httptest.ResponseRecorder, want int) {
+	t.Helper()
+	if rec.Code != want {
+		t.Fatalf("status = %d, want %d; body: %s", rec.Code, want, rec.Body.String())
+	}
+}
+
+func assertContains(t *testing.T, body, want string) {
+	t.Helper()
+	if !strings.Contains(body, want) {
+		t.Errorf("body does not contain %q:\n%s", want, body)
+	}
+}
+
+func assertMatches(t *testing.T, body, pattern string) {
+	t.Helper()
+	if !regexp.MustCompile(`(?m)` + pattern).MatchString(body) {
+		t.Errorf("body does not match %s:\n%s", pattern, body)
+	}
+}
+
+func TestAssetCacheControl(t *testing.T) {
+	config := &Config{AssetMaxAge: 60e9}
+	for path, want := range map[string]string{
+		"swagger-ui-bundle.3f2a9c1b.js":  "public, max-age=31536000, immutable",
+		"/swagger-ui-bundle.3f2a9c1b.js": "public, max-age=31536000, immutable",
+		"swagger-ui-bundle.js":           "public, max-age=60",
+	} {
+		if got := assetCacheControl(config, path); got != want {
+			t.Errorf("assetCacheControl(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestFingerprintedAssetCachedForever(t *testing.T) {
+	h := HTTPHandler("/swagger/", New(&Config{InstanceName: testInstance, Handler: memAssets(t, "app.0123abcd.js")}))
+	rec := request(h, "/swagger/app.0123abcd.js")
+	assertStatus(t, rec, http.StatusOK)
+	if got := rec.Header().Get("Cache-Control"); got != "public, max-age=31536000, immutable" {
+		t.Errorf("Cache-Control = %q", got)
+	}
+}
+
+func TestRootMount(t *testing.T) {
+	h := HTTPHandler("/", New(&Config{InstanceName: testInstance}))
+
+	rec := request(h, "/index.html")
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec.Body.String(), `href="/swagger-ui.css"`)
+	assertContains(t, rec.Body.String(), `url: "\/doc.json"`)
+
+	rec = request(h, "/doc.json")
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec.Body.String(), `"title": "test"`)
+
+	rec = request(h, "/swagger-ui.css")
+	assertStatus(t, rec, http.StatusOK)
+	if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/css") {
+		t.Errorf("Content-Type = %q", got)
+	}
+
+	rec = request(h, "/")
+	assertStatus(t, rec, http.StatusFound)
+	if got := rec.Header().Get("Location"); got != "/index.html" {
+		t.Errorf("Location = %q, want /index.html", got)
+	}
+}
+
+func TestSourceMapIsNotItsSource(t *testing.T) {
+	h := HTTPHandler("/swagger/", New(&Config{InstanceName: testInstance, Handler: memAssets(t, "swagger-ui.css", "swagger-ui.css.map")}))
+	rec := request(h, "/swagger/swagger-ui.css.map")
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec.Body.String(), "swagger-ui.css.map")
+}
+
+func TestSpecCacheControl(t *testing.T) {
+	for name, tc := range map[string]struct {
+		config *Config
+		header []string
+		want   string
+	}{
+		"default":    {&Config{}, nil, "no-cache"},
+		"max age":    {&Config{SpecMaxAge: time.Minute}, nil, "public, max-age=60"},
+		"basic auth": {&Config{SpecMaxAge: time.Minute, BasicAuth: map[string]string{"u": "p"}}, []string{"Authorization", "Basic dTpw"}, "private, max-age=60"},
+		// The schemes follow X-Forwarded-Proto, so a CDN must not serve one caller's spec to another.
+		"auto schemes": {&Config{SpecMaxAge: time.Minute, AutoSchemes: true}, []string{"X-Forwarded-Proto", "https"}, "private, max-age=60"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			tc.config.InstanceName = testInstance
+			rec := request(HTTPHandler("/swagger/", New(tc.config)), "/swagger/doc.json", tc.header...)
+			assertStatus(t, rec, http.StatusOK)
+			if got := rec.Header().Get("Cache-Control"); got != tc.want {
+				t.Errorf("Cache-Control = %q, want %q", got, tc.want)
+			}
+			etag := rec.Header().Get("ETag")
+			rec = request(HTTPHandler("/swagger/", New(tc.config)), "/swagger/doc.json", append(tc.header, "If-None-Match", etag)...)
+			assertStatus(t, rec, http.StatusNotModified)
+		})
+	}
+}
+
+func TestAutoSchemesTrustsOnlyHTTPAndHTTPS(t *testing.T) {
+	h := HTTPHandler("/swagger/", New(&Config{InstanceName: testInstance, AutoSchemes: true}))
+	for proto, want := range map[string]string{
+		"https":       `"schemes":["https"]`,
+		"HTTPS, http": `"schemes":["https"]`,
+		"javascript":  `"schemes":["http"]`,
+		"":            `"schemes":["http"]`,
+		"ftp evil":    `"schemes":["http"]`,
+	} {
+		rec := request(h, "/swagger/doc.json", "X-Forwarded-Proto", proto)
+		assertStatus(t, rec, http.StatusOK)
+		assertContains(t, rec.Body.String(), want)
+	}
+}
+
+func TestDocCacheBoundsViews(t *testing.T) {
+	config := &Config{MaxCachedSpecs: 3}
+	var d docCache
+	entry := d.put(config, "spec", &docEntry{source: []byte("a"), processed: []byte("a")})
+	for i := 0; i < 100; i++ {
+		if _, ok := d.get(config, "spec", []byte("a")); !ok {
+			t.Fatalf("entry evicted after %d views", i)
+		}
+		d.putView(config, entry, strconv.Itoa(i), []byte("view"))
+	}
+	if d.lru.Len() > config.MaxCachedSpecs {
+		t.Errorf("cache holds %d documents, want at most %d", d.lru.Len(), config.MaxCachedSpecs)
+	}
+	if len(entry.views) >= config.MaxCachedSpecs {
+		t.Errorf("entry tracks %d views, want fewer than %d", len(entry.views), config.MaxCachedSpecs)
+	}
+}
+
+func TestDynamicSpecsAreNotKeptCompressed(t *testing.T) {
+	big := strings.Repeat("x", 2*minCompressSize)
+	h := newHandler("swagger_index.html", swaggerIndexTpl, &Config{
+		InstanceName: testInstance,
+		Compress:     true,
+		SpecProvider: func(ctx *frame.Context) ([]byte, error) {
+			return []byte(`{"swagger": "2.0", "info": {"title": "` + string(ctx.GetHeader("X-Id")) + big + `"}}`), nil
+		},
+	})
+	hh := HTTPHandler("/swagger/", h.serve)
+	for i := 0; i < 5; i++ {
+		rec := request(hh, "/swagger/doc.json", "Accept-Encoding", "gzip", "X-Id", strconv.Itoa(i))
+		assertStatus(t, rec, http.StatusOK)
+		if got := rec.Header().Get("Content-Encoding"); got != "gzip" {
+			t.Fatalf("Content-Encoding = %q, want gzip", got)
+		}
+	}
+	if n := len(h.gzipped.files); n != 0 {
+		t.Errorf("compression cache holds %d provider documents", n)
+	}
+	if n := h.docs.lru.Len(); n != 1 {
+		t.Errorf("spec cache holds %d provider documents, want only the last", n)
+	}
+}
+
+func TestOperationsExpandedSchemasCollapsed(t *testing.T) {
+	h := HTTPHandler("/swagger/", New(&Config{InstanceName: testInstance, OperationsExpanded: true, SchemasCollapsed: true}))
+	rec := request(h, "/swagger/index.html")
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec.Body.String(), `docExpansion: "full"`)
+	assertMatches(t, rec.Body.String(), `defaultModelsExpandDepth:\s*0\s*$`)
+
+	rec = request(HTTPHandler("/swagger/", New(&Config{InstanceName: testInstance})), "/swagger/index.html")
+	assertContains(t, rec.Body.String(), `docExpansion: "list"`)
+	assertMatches(t, rec.Body.String(), `defaultModelsExpandDepth:\s*1\s*$`)
+}
+
+func TestBehindRewritingProxy(t *testing.T) {
+	// The proxy serves the mount /swagger/ of the app at /api/docs/ on another host.
+	forwarded := []string{"X-Forwarded-Host", "docs.example.com", "X-Forwarded-Proto", "https", "X-Forwarded-Prefix", "/api/docs"}
+	for name, tc := range map[string]struct {
+		config   *Config
+		assets   string
+		spec     string
+		location string
+	}{
+		"no base path": {
+			config:   &Config{},
+			assets:   `href="/swagger/swagger-ui.css"`,
+			spec:     `url: "\/swagger\/doc.json"`,
+			location: "/swagger/index.html",
+		},
+		"public base path": {
+			config:   &Config{PublicBasePath: "/api/docs"},
+			assets:   `href="/api/docs/swagger-ui.css"`,
+			spec:     `url: "\/api\/docs\/doc.json"`,
+			location: "/api/docs/index.html",
+		},
+		"asset prefix": {
+			config:   &Config{PublicBasePath: "/api/docs/", AssetPrefix: "https://cdn.example.com/ui"},
+			assets:   `href="https://cdn.example.com/ui/swagger-ui.css"`,
+			spec:     `url: "\/api\/docs\/doc.json"`,
+			location: "/api/docs/index.html",
+		},
+		"absolute root redirect": {
+			config:   &Config{PublicBasePath: "/api/docs/", RootRedirect: "/elsewhere"},
+			assets:   `href="/api/docs/swagger-ui.css"`,
+			spec:     `url: "\/api\/docs\/doc.json"`,
+			location: "/elsewhere",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			tc.config.InstanceName = testInstance
+			h := HTTPHandler("/swagger/", New(tc.config))
+
+			rec := request(h, "/swagger/index.html", forwarded...)
+			assertStatus(t, rec, http.StatusOK)
+			assertContains(t, rec.Body.String(), tc.assets)
+			assertContains(t, rec.Body.String(), tc.spec)
+
+			rec = request(h, "/swagger/", forwarded...)
+			assertStatus(t, rec, http.StatusFound)
+			if got := rec.Header().Get("Location"); got != tc.location {
+				t.Errorf("Location = %q, want %q", got, tc.location)
+			}
+		})
+	}
+}
+
+func TestRateLimitPerRemoteAddr(t *testing.T) {
+	h := HTTPHandler("/swagger/", New(&Config{InstanceName: testInstance, RateLimit: &RateLimit{Requests: 1, Interval: time.Hour}}))
+	serve := func(remoteAddr string, header ...string) int {
+		req := httptest.NewRequest(http.MethodGet, "/swagger/doc.json", nil)
+		req.RemoteAddr = remoteAddr
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if got := serve("192.0.2.1:1234"); got != http.StatusOK {
+		t.Fatalf("first request: status = %d", got)
+	}
+	if got := serve("192.0.2.1:1234", "X-Forwarded-For", "198.51.100.7"); got != http.StatusTooManyRequests {
+		t.Errorf("X-Forwarded-For from an untrusted client: status = %d, want 429", got)
+	}
+	if got := serve("192.0.2.2:1234"); got != http.StatusOK {
+		t.Errorf("another client: status = %d, want 200", got)
+	}
+}
+
+func TestBareMount(t *testing.T) {
+	h := HTTPHandler("/swagger/", New(&Config{InstanceName: testInstance}))
+	for _, target := range []string{"/swagger", "/swagger/"} {
+		rec := request(h, target)
+		assertStatus(t, rec, http.StatusFound)
+		if got := rec.Header().Get("Location"); got != "/swagger/index.html" {
+			t.Errorf("%s: Location = %q, want /swagger/index.html", target, got)
+		}
+	}
+
+	h = HTTPHandler("/swagger/", New(&Config{InstanceName: testInstance, Renderers: []string{RendererSwaggerUI, RendererReDoc}}))
+	for _, target := range []string{"/swagger", "/swagger/"} {
+		rec := request(h, target)
+		assertStatus(t, rec, http.StatusOK)
+		assertContains(t, rec.Body.String(), `href="/swagger/index.html"`)
+		assertContains(t, rec.Body.String(), `href="/swagger/redoc"`)
+	}
+
+	rec := request(h, "/swagger/redoc")
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec.Body.String(), `spec-url="/swagger/doc.json"`)
+}
+
+func TestContentSecurityPolicyAllowsSpecOrigin(t *testing.T) {
+	csp := contentSecurityPolicy(&Config{URL: "https://specs.example.com/v1/doc.json"}, nil)
+	assertContains(t, csp, "connect-src 'self' https://specs.example.com")
+	csp = contentSecurityPolicy(&Config{URL: "doc.json"}, nil)
+	if !strings.HasSuffix(csp, "connect-src 'self'") {
+		t.Errorf("relative URL widened connect-src: %s", csp)
+	}
+}
+
+func TestTemplateFuncsWithoutIndexTemplate(t *testing.T) {
+	h := HTTPHandler("/swagger/", New(&Config{InstanceName: testInstance, TemplateFuncs: template.FuncMap{"upper": strings.ToUpper}}))
+	rec := request(h, "/swagger/index.html")
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec.Body.String(), `id="swagger-ui"`)
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	for header, want := range map[string]bool{
+		"":                 false,
+		"gzip":             true,
+		"GZIP, br":         true,
+		"br":               false,
+		"gzip;q=0":         false,
+		"gzip;q=0.0":       false,
+		"gzip; q=0.000":    false,
+		"gzip;q=0.5":       true,
+		"gzip;q=bogus":     false,
+		"*":                true,
+		"*;q=0":            false,
+		"*;q=0, gzip":      true,
+		"gzip;q=0, *":      false,
+		"br;q=1, *;q=0.1":  true,
+		"identity, *;q=0":  false,
+		"gzip;level=1;q=1": true,
+	} {
+		ctx := frame.NewContext(0)
+		ctx.Request.Header.Set("Accept-Encoding", header)
+		if got := acceptsGzip(ctx); got != want {
+			t.Errorf("acceptsGzip(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
+
+func TestCloseStopsSpecFileWatcher(t *testing.T) {
+	interval := specWatchInterval
+	specWatchInterval = 5 * time.Millisecond
+	defer func() { specWatchInterval = interval }()
+
+	path := t.TempDir() + "/doc.json"
+	write := func(title string, modTime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(`{"swagger": "2.0", "info": {"title": "`+title+`"}}`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fetch := func(h *Handler) string {
+		return request(HTTPHandler("/swagger/", h.Serve), "/swagger/doc.json").Body.String()
+	}
+	start := time.Now().Add(-time.Hour)
+	write("one", start)
+
+	h := NewHandler(&Config{SpecFile: path, WatchSpecFile: true})
+	assertContains(t, fetch(h), `"one"`)
+	write("two", start.Add(time.Minute))
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(fetch(h), `"two"`) {
+		if time.Now().After(deadline) {
+			t.Fatal("watched spec file was not reloaded")
+		}
+		time.Sleep(specWatchInterval)
+	}
+
+	old := h.current.Load()
+	h.UpdateConfig(&Config{SpecFile: path, WatchSpecFile: true})
+	select {
+	case <-old.spec.stop:
+	default:
+		t.Error("UpdateConfig left the previous spec file watched")
+	}
+
+	current := h.current.Load()
+	h.Close()
+	select {
+	case <-current.spec.stop:
+	default:
+		t.Error("Close left the spec file watched")
+	}
+	h.Close()
+}
+
+func TestGenerateStaticSite(t *testing.T) {
+	dir := t.TempDir()
+	if err := GenerateStaticSite(&Config{InstanceName: testInstance, Handler: memAssets(t, "swagger-ui.css")}, dir); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"index.html", "doc.json", "swagger-ui.css"} {
+		if _, err := os.Stat(dir + "/" + name); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// The default instance isn't registered here, but a nil config must not panic.
+	if err := GenerateStaticSite(nil, t.TempDir()); err == nil || !strings.Contains(err.Error(), "doc.json") {
+		t.Errorf("GenerateStaticSite(nil) = %v, want an error reading doc.json", err)
+	}
+}
+
+func TestMustNew(t *testing.T) {
+	rec := request(HTTPHandler("/swagger/", MustNew(nil)), "/swagger/index.html")
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec.Body.String(), `url: "\/swagger\/doc.json"`)
+
+	defer func() {
+		if recover() == nil {
+			t.Error("MustNew accepted an invalid config")
+		}
+	}()
+	MustNew(&Config{TemplateFuncs: template.FuncMap{"upper": strings.ToUpper}})
+}
+
+func TestUpdateConfig(t *testing.T) {
+	h := NewHandler(&Config{InstanceName: testInstance, Title: "Before"})
+	hh := HTTPHandler("/swagger/", h.Serve)
+	assertContains(t, request(hh, "/swagger/index.html").Body.String(), "<title>Before</title>")
+
+	h.UpdateConfig(&Config{InstanceName: testInstance, Title: "After"})
+	assertContains(t, request(hh, "/swagger/index.html").Body.String(), "<title>After</title>")
+
+	h.UpdateConfig(nil)
+	rec := request(hh, "/swagger/index.html")
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec.Body.String(), "<title>Swagger UI</title>")
+}
+
+func TestEffectiveConfigDropsIgnoredOptions(t *testing.T) {
+	cfg := &Config{
+		CompressionLevel:  42,
+		GoogleAnalyticsID: "not an id",
+		AnalyticsScript:   "</script><script>alert(1)",
+		RateLimit:         &RateLimit{Requests: 0, Interval: time.Minute},
+		TemplateFuncs:     template.FuncMap{"upper": strings.ToUpper},
+		ErrorTemplate:     "{{",
+		DisabledStatus:    42,
+		Layout:            "Nope",
+		SpecLineEndings:   "cr",
+	}
+	config := EffectiveConfig(cfg)
+	if config.CompressionLevel != gzip.DefaultCompression || config.GoogleAnalyticsID != "" || config.AnalyticsScript != "" ||
+		config.RateLimit != nil || config.TemplateFuncs != nil || config.ErrorTemplate != "" {
+		t.Errorf("EffectiveConfig kept ignored options: %+v", config)
+	}
+	if config.DisabledStatus != http.StatusNotFound || config.DisabledMessage != http.StatusText(http.StatusNotFound) ||
+		config.Layout != StandaloneLayout || config.SpecLineEndings != "" {
+		t.Errorf("EffectiveConfig reports values the handler doesn't use: %+v", config)
+	}
+	if cfg.GoogleAnalyticsID != "not an id" || cfg.RateLimit == nil {
+		t.Error("EffectiveConfig modified its argument")
+	}
+
+	config = EffectiveConfig(&Config{GoogleAnalyticsID: "G-ABC123", RateLimit: &RateLimit{Requests: 1, Interval: time.Minute}})
+	if config.GoogleAnalyticsID != "G-ABC123" || config.RateLimit == nil {
+		t.Errorf("EffectiveConfig dropped valid options: %+v", config)
+	}
+}
+
+func TestDefaultParameterSerialization(t *testing.T) {
+	if _, err := NewWithError(&Config{InstanceName: testInstance, DefaultParameterSerialization: "commas"}); err == nil {
+		t.Error("NewWithError accepted an unknown DefaultParameterSerialization")
+	}
+	for _, format := range []string{"csv", "multi", "ssv", "tsv", "pipes"} {
+		if _, err := NewWithError(&Config{InstanceName: testInstance, DefaultParameterSerialization: format}); err != nil {
+			t.Errorf("%s: %v", format, err)
+		}
+	}
+
+	doc := []byte(`{"swagger": "2.0", "paths": {"/x": {"get": {"parameters": [{"in": "query", "name": "ids", "type": "array"}]}}}}`)
+	out, err := processDoc(&Config{DefaultParameterSerialization: "commas"}, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(out), "collectionFormat") {
+		t.Errorf("an unknown format was applied: %s", out)
+	}
+	out, err = processDoc(&Config{DefaultParameterSerialization: "tsv"}, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, string(out), `"collectionFormat":"tsv"`)
+}
+
+func TestDefaultSnippetLang(t *testing.T) {
+	if _, err := NewWithError(&Config{InstanceName: testInstance, DefaultSnippetLang: "python"}); err == nil {
+		t.Error("NewWithError accepted an unknown DefaultSnippetLang")
+	}
+
+	page := func(lang string) string {
+		h := HTTPHandler("/swagger/", New(&Config{InstanceName: testInstance, RequestSnippetsEnabled: true, DefaultSnippetLang: lang}))
+		rec := request(h, "/swagger/index.html")
+		assertStatus(t, rec, http.StatusOK)
+		return rec.Body.String()
+	}
+	body := page("curl_cmd")
+	assertContains(t, body, `const lang = "curl_cmd";`)
+	assertMatches(t, body, `DownloadUrl,\s*DefaultSnippetPlugin\s*\]`)
+	for _, lang := range []string{"", "python"} {
+		if body := page(lang); strings.Contains(body, "DefaultSnippetPlugin") {
+			t.Errorf("DefaultSnippetLang %q loads the plugin", lang)
+		}
+	}
+}
+
+func TestRendererScriptsArePinned(t *testing.T) {
+	for name, tpl := range map[string]string{"redoc": redocIndexTpl, "rapidoc": rapidocIndexTpl} {
+		if strings.Contains(tpl, "/latest/") || regexp.MustCompile(`unpkg\.com/[\w-]+/`).MatchString(tpl) {
+			t.Errorf("%s page loads an unpinned script", name)
+		}
+	}
+}
+
+func TestDocsIndex(t *testing.T) {
+	for name, tc := range map[string]struct {
+		config *Config
+		want   []string
+	}{
+		"instance": {
+			&Config{InstanceName: testInstance},
+			[]string{`"source":"instance"`, `"instance":"swagger_test"`, `"formats":{"json":"doc.json"}`},
+		},
+		"instance names": {
+			&Config{InstanceNames: []string{"swagger_test_missing", testInstance}},
+			[]string{`"source":"instance"`, `"instance":"swagger_test"`},
+		},
+		"spec file": {
+			&Config{SpecFile: "/etc/api/doc.json"},
+			[]string{`"source":"file"`},
+		},
+		"provider": {
+			&Config{URL: "https://specs.example.com/doc.json", SpecProvider: func(*frame.Context) ([]byte, error) { return []byte(testDoc), nil }},
+			[]string{`"source":"provider"`, `"spec":"https://specs.example.com/doc.json"`, `"formats":{"json":"https://specs.example.com/doc.json"}`},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			rec := request(HTTPHandler("/swagger/", New(tc.config)), "/swagger/index.json")
+			assertStatus(t, rec, http.StatusOK)
+			for _, want := range tc.want {
+				assertContains(t, rec.Body.String(), want)
+			}
+			if strings.Contains(rec.Body.String(), "/etc/api") {
+				t.Errorf("index.json reveals the spec file path: %s", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestDereferenceRefs(t *testing.T) {
+	for name, tc := range map[string]struct {
+		doc  string
+		want []string
+	}{
+		"circular pair": {
+			`{"definitions": {"A": {"properties": {"b": {"$ref": "#/definitions/B"}}}, "B": {"properties": {"a": {"$ref": "#/definitions/A"}}}},
+			  "paths": {"/a": {"get": {"responses": {"200": {"schema": {"$ref": "#/definitions/A"}}}}}}}`,
+			[]string{`"schema":{"properties":{"b":{"properties":{"a":{"$ref":"#/definitions/A"}}}}}`},
+		},
+		"array pointer": {
+			`{"paths": {"/a": {"get": {"parameters": [{"in": "query", "name": "q"}]}, "post": {"parameters": [{"$ref": "#/paths/~1a/get/parameters/0"}]}}}}`,
+			[]string{`"post":{"parameters":[{"in":"query","name":"q"}]}`},
+		},
+		"dangling": {
+			`{"paths": {"/a": {"get": {"parameters": [{"$ref": "#/parameters/missing"}, {"$ref": "#/paths/~1a/get/parameters/7"}]}}}}`,
+			[]string{`{"$ref":"#/parameters/missing"}`, `{"$ref":"#/paths/~1a/get/parameters/7"}`},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			out, err := dereference([]byte(tc.doc))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tc.want {
+				assertContains(t, string(out), want)
+			}
+		})
+	}
+}
+
+func TestSpecFileWatcherUsesInotify(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("inotify is Linux only")
+	}
+	interval := specWatchInterval
+	specWatchInterval = time.Hour
+	defer func() { specWatchInterval = interval }()
+
+	path := t.TempDir() + "/doc.json"
+	if err := os.WriteFile(path, []byte(`{"swagger": "2.0", "info": {"title": "one"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(&Config{SpecFile: path, WatchSpecFile: true})
+	defer h.Close()
+	hh := HTTPHandler("/swagger/", h.Serve)
+	assertContains(t, request(hh, "/swagger/doc.json").Body.String(), `"one"`)
+
+	// Replace the file by a rename, as generators and editors do.
+	if err := os.WriteFile(path+".tmp", []byte(`{"swagger": "2.0", "info": {"title": "two"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path+".tmp", later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(request(hh, "/swagger/doc.json").Body.String(), `"two"`) {
+		if time.Now().After(deadline) {
+			t.Fatal("the renamed spec file was not reloaded")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDiscardedHandlerStopsSpecFileWatcher(t *testing.T) {
+	path := t.TempDir() + "/doc.json"
+	if err := os.WriteFile(path, []byte(testDoc), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stop := newHandler("swagger_index.html", swaggerIndexTpl, &Config{SpecFile: path, WatchSpecFile: true}).spec.stop
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		runtime.GC()
+		select {
+		case <-stop:
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the watcher of a discarded handler kept running")
+		}
+	}
+}
+
+func TestInvalidOptionsFallBackToDefaults(t *testing.T) {
+	h := HTTPHandler("/swagger/", New(&Config{InstanceName: testInstance, Disabled: true, DisabledStatus: 42}))
+	rec := request(h, "/swagger/index.html")
+	assertStatus(t, rec, http.StatusNotFound)
+	assertContains(t, rec.Body.String(), http.StatusText(http.StatusNotFound))
+
+	h = HTTPHandler("/swagger/", New(&Config{InstanceName: testInstance, Layout: "Nope"}))
+	assertContains(t, request(h, "/swagger/index.html").Body.String(), `layout: "StandaloneLayout"`)
+
+	h = HTTPHandler("/swagger/", New(&Config{InstanceName: testInstance, SpecLineEndings: "cr"}))
+	rec = request(h, "/swagger/doc.json")
+	assertStatus(t, rec, http.StatusOK)
+	if rec.Body.String() != testDoc {
+		t.Errorf("doc.json = %q, want it served as is", rec.Body.String())
+	}
+}
+
+const tagsInstance = "swagger_test_tags"
+
+const tagsDoc = `{"swagger": "2.0", "info": {"title": "tags"},
+  "tags": [{"name": "public"}, {"name": "admin"}],
+  "paths": {
+    "/public": {"get": {"tags": ["public"]}},
+    "/admin": {"get": {"tags": ["admin"]}},
+    "/mixed": {"get": {"tags": ["public", "admin"]}},
+    "/health": {"get": {}}
+  }}`
+
+type tagsSpec struct{}
+
+func (tagsSpec) ReadDoc() string { return tagsDoc }
+
+func init() {
+	swag.Register(tagsInstance, tagsSpec{})
+}
+
+func TestTagAccess(t *testing.T) {
+	// Anonymous callers see the public tag, operators the untagged operations and admins everything.
+	access := func(ctx *frame.Context, tag string) bool {
+		switch string(ctx.GetHeader("X-Role")) {
+		case "admin":
+			return true
+		case "ops":
+			return tag == "public" || tag == untaggedTag
+		}
+		return tag == "public"
+	}
+	h := newHandler("swagger_index.html", swaggerIndexTpl, &Config{InstanceName: tagsInstance, TagAccess: access})
+	hh := HTTPHandler("/swagger/", h.serve)
+	fetch := func(path, role string) string {
+		rec := request(hh, path, "X-Role", role)
+		assertStatus(t, rec, http.StatusOK)
+		return rec.Body.String()
+	}
+
+	for role, tc := range map[string]struct {
+		visible, hidden []string
+	}{
+		"":      {[]string{`"/public"`}, []string{`"/admin"`, `"/health"`, `"name":"admin"`}},
+		"ops":   {[]string{`"/public"`, `"/health"`}, []string{`"/admin"`}},
+		"admin": {[]string{`"/public"`, `"/admin"`, `"/health"`, `"name":"admin"`}, nil},
+	} {
+		for _, path := range []string{"/swagger/doc.json", "/swagger/operations.json"} {
+			body := fetch(path, role)
+			for _, want := range tc.visible {
+				if path == "/swagger/operations.json" && strings.HasPrefix(want, `"name"`) {
+					continue
+				}
+				assertContains(t, body, want)
+			}
+			for _, hidden := range tc.hidden {
+				if strings.Contains(body, hidden) {
+					t.Errorf("%s for role %q contains %s:\n%s", path, role, hidden, body)
+				}
+			}
+		}
+	}
+
+	// An operation is shown when any one of its tags is allowed.
+	assertContains(t, fetch("/swagger/doc.json", ""), `"/mixed"`)
+
+	// One processed document and a view per set of allowed tags.
+	fetch("/swagger/doc.json", "")
+	if n := h.docs.lru.Len(); n != 4 {
+		t.Errorf("spec cache holds %d documents, want the processed one and 3 views", n)
+	}
+}
+
+func TestBasicAuth(t *testing.T) {
+	h := HTTPHandler("/swagger/", New(&Config{InstanceName: testInstance, BasicAuth: map[string]string{"u": "p"}, Handler: memAssets(t, "swagger-ui.css")}))
+	for _, path := range []string{"/swagger/index.html", "/swagger/doc.json", "/swagger/operations.json", "/swagger/swagger-ui.css"} {
+		assertStatus(t, request(h, path), http.StatusUnauthorized)
+		assertStatus(t, request(h, path, "Authorization", "Basic dTp3cm9uZw=="), http.StatusUnauthorized)
+		assertStatus(t, request(h, path, "Authorization", "Basic dTpw"), http.StatusOK)
+	}
+	if got := request(h, "/swagger/doc.json").Header().Get("WWW-Authenticate"); !strings.HasPrefix(got, "Basic ") {
+		t.Errorf("WWW-Authenticate = %q", got)
+	}
+
+	// The page sends the credentials along with its requests for doc.json.
+	assertMatches(t, request(h, "/swagger/index.html", "Authorization", "Basic dTpw").Body.String(), `withCredentials:\s*true\s*,`)
+	assertMatches(t, request(HTTPHandler("/swagger/", New(&Config{InstanceName: testInstance})), "/swagger/index.html").Body.String(), `withCredentials:\s*false\s*,`)
+}
+
+func TestStatsCountEachAssetFetchOnce(t *testing.T) {
+	h := newHandler("swagger_index.html", swaggerIndexTpl, &Config{InstanceName: testInstance, EnableStats: true, Handler: memAssets(t, "swagger-ui.css")})
+	hh := HTTPHandler("/swagger/", h.serve)
+	for i := 0; i < 3; i++ {
+		assertStatus(t, request(hh, "/swagger/swagger-ui.css", "Accept-Encoding", "gzip"), http.StatusOK)
+	}
+	if got := h.assets.counters.stats(); got != (cacheStats{Hits: 2, Misses: 1}) {
+		t.Errorf("asset stats = %+v, want 2 hits and 1 miss", got)
+	}
+}
+
+func TestRegisterRootSpec(t *testing.T) {
+	engine := route.NewEngine(config.NewOptions(nil))
+	RegisterRootSpec(engine, nil)
+	RegisterRootSpec(engine, &Config{InstanceName: testInstance})
+	if routes := engine.Routes(); len(routes) != 0 {
+		t.Errorf("registered %v without a RootSpecPath", routes)
+	}
+	RegisterRootSpec(engine, &Config{InstanceName: testInstance, RootSpecPath: "/openapi.json"})
+	if routes := engine.Routes(); len(routes) != 1 || routes[0].Path != "/openapi.json" {
+		t.Errorf("routes = %v, want GET /openapi.json", routes)
+	}
+}
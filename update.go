@@ -0,0 +1,43 @@
+package swagger
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/oarkflow/frame"
+)
+
+// Handler serves Swagger UI like the handler returned by New, but its configuration
+// can be replaced at runtime, e.g. from a feature flag, without registering it again.
+type Handler struct {
+	name     string
+	indexTpl string
+	current  atomic.Pointer[handler]
+}
+
+// NewHandler returns a Handler; register its Serve method on the router.
+func NewHandler(cfg ...*Config) *Handler {
+	h := &Handler{name: "swagger_index.html", indexTpl: swaggerIndexTpl}
+	h.current.Store(newHandler(h.name, h.indexTpl, cfg...))
+	return h
+}
+
+// Serve handles a request with the current configuration.
+func (h *Handler) Serve(c context.Context, ctx *frame.Context) {
+	h.current.Load().serve(c, ctx)
+}
+
+// UpdateConfig replaces the configuration. Requests already being served finish with
+// the previous one; later requests, including the index page, use cfg. Caches start
+// empty, and cfg must not be modified afterwards; a nil cfg restores the defaults. The
+// previous configuration stops watching its SpecFile.
+func (h *Handler) UpdateConfig(cfg *Config) {
+	h.current.Swap(newHandler(h.name, h.indexTpl, cfg)).close()
+}
+
+// Close stops watching the SpecFile of the current configuration when WatchSpecFile is
+// set, without waiting for the handler to be discarded. The handler keeps serving the
+// document last read.
+func (h *Handler) Close() {
+	h.current.Load().close()
+}